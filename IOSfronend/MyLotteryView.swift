@@ -179,7 +179,18 @@ struct LotteryView: View {
     }
     
     func fetchLotteries() {
-        guard let url = URL(string: "\(BASE_URL)/lottery/") else { return }
+        fetchLotteryPage(cursor: nil, accumulated: [])
+    }
+    
+    // ดึงทีละหน้าตาม X-Next-Cursor จนครบทุกใบ
+    func fetchLotteryPage(cursor: String?, accumulated: [Lottery]) {
+        var components = URLComponents(string: "\(BASE_URL)/lottery/")
+        var items = [URLQueryItem(name: "limit", value: "200")]
+        if let cursor = cursor {
+            items.append(URLQueryItem(name: "cursor", value: cursor))
+        }
+        components?.queryItems = items
+        guard let url = components?.url else { return }
         var request = URLRequest(url: url)
         request.httpMethod = "GET"
         request.setValue("application/json", forHTTPHeaderField: "Content-Type")
@@ -188,16 +199,21 @@ struct LotteryView: View {
             request.setValue("Bearer \(token)", forHTTPHeaderField: "Authorization")
         }
         
-        URLSession.shared.dataTask(with: request) { data, _, _ in
+        URLSession.shared.dataTask(with: request) { data, response, _ in
+            guard let data = data, let page = try? JSONDecoder().decode([Lottery].self, from: data) else { return }
+            let all = accumulated + page
+            let next = (response as? HTTPURLResponse)?.value(forHTTPHeaderField: "X-Next-Cursor")
+            if let next = next, !next.isEmpty {
+                self.fetchLotteryPage(cursor: next, accumulated: all)
+                return
+            }
             DispatchQueue.main.async {
-                if let data = data, var decoded = try? JSONDecoder().decode([Lottery].self, from: data) {
-                    decoded = decoded.map { lottery in
-                        var l = lottery
-                        l.round = convertToBuddhistYear(l.round)
-                        return l
-                    }
-                    self.lotteries = decoded.sorted { $0.round > $1.round }
+                let decoded = all.map { lottery -> Lottery in
+                    var l = lottery
+                    l.round = convertToBuddhistYear(l.round)
+                    return l
                 }
+                self.lotteries = decoded.sorted { $0.round > $1.round }
             }
         }.resume()
     }
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BlobStore เก็บไฟล์ (เช่น รูปหลักฐานของสลาก) โดยอ้างถึงด้วย key แบบ "lottery/xxx.jpg"
+type BlobStore interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL คือที่อยู่ถาวรของไฟล์ ใช้เก็บใน image_url
+	URL(key string) string
+	// SignedURL คือลิงก์ดาวน์โหลดชั่วคราวที่หมดอายุตาม expires
+	SignedURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	// MakePrivate ปิดการเข้าถึงแบบสาธารณะของไฟล์ที่อัปโหลดไว้ก่อนหน้า
+	MakePrivate(ctx context.Context, key string) error
+	// SignedPutURL คือลิงก์ชั่วคราวให้ client อัปโหลดไฟล์ขึ้นที่เก็บเองโดยตรง
+	// ไฟล์ต้องมีขนาด size ไบต์และ Content-Type ตรงกับที่ระบุ client ต้องส่ง header ที่คืนไปด้วย
+	SignedPutURL(ctx context.Context, key, contentType string, size int64, expires time.Duration) (string, map[string]string, error)
+	// Size คืนขนาดของไฟล์ หรือ ErrBlobNotFound เมื่อยังไม่มีไฟล์
+	Size(ctx context.Context, key string) (int64, error)
+}
+
+// ErrBlobNotFound คืนจาก Get เมื่อไม่มีไฟล์
+var ErrBlobNotFound = errors.New("blob not found")
+
+var Blobs BlobStore
+
+// LocalBlobStore เก็บไฟล์ในโฟลเดอร์ Dir และให้ดาวน์โหลดผ่าน BaseURL (GET /files/*key) ด้วยลิงก์ที่ลงลายเซ็นเท่านั้น
+// ใช้สำหรับ development และ CI ที่ไม่มี AWS
+type LocalBlobStore struct {
+	Dir        string
+	BaseURL    string
+	SigningKey []byte
+}
+
+// path คืนตำแหน่งไฟล์ของ key โดยไม่ให้ออกนอก Dir
+func (s *LocalBlobStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, body, 0o644)
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalBlobStore) URL(key string) string {
+	return s.BaseURL + "/" + strings.TrimLeft(key, "/")
+}
+
+func (s *LocalBlobStore) SignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	exp := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", exp)
+	q.Set("signature", s.sign(key, exp))
+	return s.URL(key) + "?" + q.Encode(), nil
+}
+
+// MakePrivate ไม่ต้องทำอะไร ไฟล์ใน LocalBlobStore เปิดได้ด้วยลิงก์ที่ลงลายเซ็นเท่านั้น
+func (s *LocalBlobStore) MakePrivate(ctx context.Context, key string) error {
+	return nil
+}
+
+func (s *LocalBlobStore) SignedPutURL(ctx context.Context, key, contentType string, size int64, expires time.Duration) (string, map[string]string, error) {
+	exp := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	length := strconv.FormatInt(size, 10)
+	q := url.Values{}
+	q.Set("expires", exp)
+	q.Set("signature", s.sign(key, exp, "PUT", contentType, length))
+	headers := map[string]string{"Content-Type": contentType}
+	return s.URL(key) + "?" + q.Encode(), headers, nil
+}
+
+func (s *LocalBlobStore) Size(ctx context.Context, key string) (int64, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return 0, ErrBlobNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// sign ลงลายเซ็น key กับเวลาหมดอายุ ลิงก์อัปโหลดจะลงลายเซ็นเมธอด ชนิดไฟล์ และขนาดด้วย
+func (s *LocalBlobStore) sign(key, expires string, extra ...string) string {
+	mac := hmac.New(sha256.New, s.SigningKey)
+	mac.Write([]byte(strings.Join(append([]string{strings.TrimLeft(key, "/"), expires}, extra...), "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature ตรวจลิงก์ที่สร้างจาก SignedURL ว่าถูกต้องและยังไม่หมดอายุ
+func (s *LocalBlobStore) VerifySignature(key, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(s.sign(key, expires)), []byte(signature))
+}
+
+// VerifyPutSignature ตรวจลิงก์ที่สร้างจาก SignedPutURL กับ Content-Type และขนาดของคำขอที่ส่งมา
+func (s *LocalBlobStore) VerifyPutSignature(key, expires, signature, contentType string, size int64) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	want := s.sign(key, expires, "PUT", contentType, strconv.FormatInt(size, 10))
+	return hmac.Equal([]byte(want), []byte(signature))
+}
+
+// LoadBlobStore เลือกที่เก็บไฟล์จาก STORAGE_DRIVER: s3, minio หรือ local
+// ถ้าไม่กำหนดจะใช้ s3 เมื่อมี AWS_BUCKET_NAME ไม่เช่นนั้นใช้ local
+func LoadBlobStore() {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+		if os.Getenv("AWS_BUCKET_NAME") != "" {
+			driver = "s3"
+		}
+	}
+
+	switch driver {
+	case "s3":
+		Blobs = newS3BlobStore("")
+	case "minio":
+		endpoint := strings.TrimRight(os.Getenv("STORAGE_ENDPOINT"), "/")
+		if endpoint == "" {
+			log.Fatal("STORAGE_ENDPOINT is not set in .env")
+		}
+		Blobs = newS3BlobStore(endpoint)
+	case "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		baseURL := strings.TrimRight(os.Getenv("STORAGE_PUBLIC_URL"), "/")
+		if baseURL == "" {
+			baseURL = strings.TrimRight(os.Getenv("APP_BASE_URL"), "/") + "/files"
+		}
+		Blobs = &LocalBlobStore{Dir: dir, BaseURL: baseURL, SigningKey: blobSigningKey()}
+	default:
+		log.Fatal("STORAGE_DRIVER must be s3, minio or local")
+	}
+	log.Println("Blob store Initialized (" + driver + ")")
+}
+
+// blobSigningKey คืน STORAGE_SIGNING_KEY หรือกุญแจที่ derive จาก JWT_SECRET เมื่อไม่ได้ตั้งค่า
+// ไม่ใช้ JWT_SECRET ตรง ๆ เพื่อไม่ให้ลายเซ็นของลิงก์ไฟล์ใช้แทนลายเซ็น token ได้
+func blobSigningKey() []byte {
+	if key := os.Getenv("STORAGE_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	mac := hmac.New(sha256.New, []byte(JWTSecret))
+	mac.Write([]byte("blob-url"))
+	return mac.Sum(nil)
+}
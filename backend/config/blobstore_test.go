@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBlobStore(t *testing.T) *LocalBlobStore {
+	t.Helper()
+	return &LocalBlobStore{
+		Dir:        t.TempDir(),
+		BaseURL:    "http://localhost:8080/files",
+		SigningKey: []byte("test-signing-key"),
+	}
+}
+
+// signedQuery แยก key, expires และ signature ออกจากลิงก์ของ LocalBlobStore
+func signedQuery(t *testing.T, s *LocalBlobStore, link string) (string, string, string) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse %q: %v", link, err)
+	}
+	key := strings.TrimPrefix(link[:strings.Index(link, "?")], s.BaseURL+"/")
+	return key, u.Query().Get("expires"), u.Query().Get("signature")
+}
+
+func TestLocalBlobStoreRoundTrip(t *testing.T) {
+	s := newTestBlobStore(t)
+	ctx := context.Background()
+	key := "lottery/abc-def.jpg"
+
+	if err := s.Put(ctx, key, []byte("hello"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	size, err := s.Size(ctx, key)
+	if err != nil || size != 5 {
+		t.Fatalf("Size = %d, %v; want 5, nil", size, err)
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	body, _ := io.ReadAll(r)
+	r.Close()
+	if string(body) != "hello" {
+		t.Fatalf("Get = %q, want %q", body, "hello")
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, key); err != ErrBlobNotFound {
+		t.Fatalf("Get after Delete = %v, want ErrBlobNotFound", err)
+	}
+	if _, err := s.Size(ctx, key); err != ErrBlobNotFound {
+		t.Fatalf("Size after Delete = %v, want ErrBlobNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing blob = %v, want nil", err)
+	}
+}
+
+func TestLocalBlobStoreStaysInsideDir(t *testing.T) {
+	s := newTestBlobStore(t)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "../../outside.txt", []byte("x"), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir, "outside.txt")); err != nil {
+		t.Fatalf("file was not written inside Dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(s.Dir), "outside.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file escaped Dir")
+	}
+
+	if err := s.Put(ctx, "/", []byte("x"), "text/plain"); err == nil {
+		t.Fatalf("Put with an empty key succeeded")
+	}
+}
+
+func TestLocalBlobStoreSignedURL(t *testing.T) {
+	s := newTestBlobStore(t)
+	link, err := s.SignedURL(context.Background(), "lottery/a.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	if !strings.HasPrefix(link, s.URL("lottery/a.jpg")+"?") {
+		t.Fatalf("SignedURL = %q, want prefix %q", link, s.URL("lottery/a.jpg"))
+	}
+
+	key, expires, signature := signedQuery(t, s, link)
+	if !s.VerifySignature(key, expires, signature) {
+		t.Fatalf("VerifySignature rejected a fresh link")
+	}
+	if s.VerifySignature("lottery/b.jpg", expires, signature) {
+		t.Errorf("VerifySignature accepted another key")
+	}
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	if s.VerifySignature(key, later, signature) {
+		t.Errorf("VerifySignature accepted a changed expiry")
+	}
+
+	other := &LocalBlobStore{Dir: s.Dir, BaseURL: s.BaseURL, SigningKey: []byte("other")}
+	if other.VerifySignature(key, expires, signature) {
+		t.Errorf("VerifySignature accepted a link signed with another key")
+	}
+}
+
+func TestLocalBlobStoreSignedURLExpires(t *testing.T) {
+	s := newTestBlobStore(t)
+	link, _ := s.SignedURL(context.Background(), "lottery/a.jpg", -time.Minute)
+	key, expires, signature := signedQuery(t, s, link)
+	if s.VerifySignature(key, expires, signature) {
+		t.Fatalf("VerifySignature accepted an expired link")
+	}
+}
+
+func TestLocalBlobStoreSignedPutURL(t *testing.T) {
+	s := newTestBlobStore(t)
+	link, headers, err := s.SignedPutURL(context.Background(), "uploads/1", "image/png", 1234, time.Minute)
+	if err != nil {
+		t.Fatalf("SignedPutURL: %v", err)
+	}
+	if headers["Content-Type"] != "image/png" {
+		t.Fatalf("headers = %v, want Content-Type image/png", headers)
+	}
+
+	key, expires, signature := signedQuery(t, s, link)
+	if !s.VerifyPutSignature(key, expires, signature, "image/png", 1234) {
+		t.Fatalf("VerifyPutSignature rejected a matching upload")
+	}
+	if s.VerifyPutSignature(key, expires, signature, "image/jpeg", 1234) {
+		t.Errorf("VerifyPutSignature accepted another content type")
+	}
+	if s.VerifyPutSignature(key, expires, signature, "image/png", 1235) {
+		t.Errorf("VerifyPutSignature accepted another size")
+	}
+	// ลิงก์อัปโหลดใช้ดาวน์โหลดไม่ได้
+	if s.VerifySignature(key, expires, signature) {
+		t.Errorf("VerifySignature accepted an upload link")
+	}
+}
+
+func TestBlobSigningKey(t *testing.T) {
+	prev := JWTSecret
+	JWTSecret = "jwt-secret"
+	t.Cleanup(func() { JWTSecret = prev })
+
+	t.Setenv("STORAGE_SIGNING_KEY", "")
+	derived := blobSigningKey()
+	if len(derived) == 0 || bytes.Equal(derived, []byte(JWTSecret)) {
+		t.Fatalf("derived key = %x, want a key separate from JWT_SECRET", derived)
+	}
+	if !bytes.Equal(derived, blobSigningKey()) {
+		t.Fatal("derived key is not stable")
+	}
+
+	t.Setenv("STORAGE_SIGNING_KEY", "storage-key")
+	if got := blobSigningKey(); string(got) != "storage-key" {
+		t.Fatalf("key = %q, want STORAGE_SIGNING_KEY", got)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +19,21 @@ var (
 	mongoOnce sync.Once
 	MongoURI  string
 	JWTSecret string
+
+	AdminUsernames []string
+
+	// ระยะเวลาก่อนลบบัญชีจริงหลังผู้ใช้ขอลบ (ACCOUNT_DELETION_GRACE_DAYS, ค่าเริ่มต้น 30 วัน)
+	AccountDeletionGrace time.Duration
+
+	// ระยะเวลาที่สลากอยู่ในถังขยะก่อนถูกลบถาวร (TRASH_RETENTION_DAYS, ค่าเริ่มต้น 30 วัน)
+	TrashRetention time.Duration
+
+	// อายุของลิงก์ดูรูปหลักฐานที่ API ส่งให้ client (IMAGE_URL_TTL_MINUTES, ค่าเริ่มต้น 15 นาที)
+	ImageURLTTL time.Duration
+
+	// จำนวนรูปที่ decode/แปลงพร้อมกันได้สูงสุด (IMAGE_PROCESS_CONCURRENCY, ค่าเริ่มต้น 2)
+	// รูปหนึ่งใช้หน่วยความจำได้หลายร้อย MB จึงต้องจำกัดไว้
+	ImageProcessConcurrency int
 )
 
 func LoadEnv() {
@@ -31,6 +48,33 @@ func LoadEnv() {
 	if JWTSecret == "" {
 		log.Fatal("JWT_SECRET is not set in .env")
 	}
+
+	AccountDeletionGrace = 30 * 24 * time.Hour
+	if days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")); err == nil && days >= 0 {
+		AccountDeletionGrace = time.Duration(days) * 24 * time.Hour
+	}
+
+	TrashRetention = 30 * 24 * time.Hour
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		TrashRetention = time.Duration(days) * 24 * time.Hour
+	}
+
+	ImageURLTTL = 15 * time.Minute
+	if minutes, err := strconv.Atoi(os.Getenv("IMAGE_URL_TTL_MINUTES")); err == nil && minutes > 0 {
+		ImageURLTTL = time.Duration(minutes) * time.Minute
+	}
+
+	ImageProcessConcurrency = 2
+	if n, err := strconv.Atoi(os.Getenv("IMAGE_PROCESS_CONCURRENCY")); err == nil && n > 0 {
+		ImageProcessConcurrency = n
+	}
+
+	AdminUsernames = nil
+	for _, name := range strings.Split(os.Getenv("ADMIN_USERNAMES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			AdminUsernames = append(AdminUsernames, name)
+		}
+	}
 }
 
 func ConnectDB() *mongo.Client {
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mailer ส่งอีเมลแบบข้อความธรรมดา
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+var MailClient Mailer
+var MailFrom string
+var AppBaseURL string
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	msg := buildMessage(m.From, to, subject, body)
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, msg)
+}
+
+// FileMailer เขียนอีเมลลงไฟล์ .eml ใน Dir (หรือพิมพ์ลง log ถ้าไม่กำหนด Dir)
+// ใช้สำหรับ development และการทดสอบ
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(to, subject, body string) error {
+	msg := buildMessage(m.From, to, subject, body)
+
+	if m.Dir == "" {
+		log.Printf("📧 mail to=%s subject=%q\n%s", to, subject, body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitizeFileName(to))
+	return os.WriteFile(filepath.Join(m.Dir, name), msg, 0o644)
+}
+
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	return []byte(b.String())
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func LoadMailer() {
+	MailFrom = os.Getenv("MAIL_FROM")
+	if MailFrom == "" {
+		MailFrom = "no-reply@luckypus.local"
+	}
+
+	AppBaseURL = strings.TrimRight(os.Getenv("APP_BASE_URL"), "/")
+
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			log.Fatal("SMTP_HOST is not set in .env")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		MailClient = &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     MailFrom,
+		}
+		log.Println("Mailer Initialized (smtp)")
+	default:
+		MailClient = &FileMailer{
+			Dir:  os.Getenv("MAIL_DIR"),
+			From: MailFrom,
+		}
+		log.Println("Mailer Initialized (file)")
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// OIDCProvider ผู้ให้บริการ OpenID Connect ที่ใช้เข้าสู่ระบบได้
+// ClientIDs คือ audience ที่ยอมรับใน ID token (เช่น Bundle ID ของแอป iOS หรือ LINE Channel ID)
+type OIDCProvider struct {
+	Name      string
+	Issuer    string
+	JWKSURL   string // รองรับ https:// และ file:// สำหรับ issuer ทดสอบในเครื่อง
+	ClientIDs []string
+}
+
+var OIDCProviders = map[string]OIDCProvider{}
+
+func LoadOIDC() {
+	OIDCProviders = map[string]OIDCProvider{}
+
+	loadOIDCProvider("apple", "https://appleid.apple.com", "https://appleid.apple.com/auth/keys")
+	loadOIDCProvider("line", "https://access.line.me", "https://api.line.me/oauth2/v2.1/certs")
+	// issuer ทดสอบ สำหรับ development/CI โดยไม่ต้องเชื่อมต่อ Apple หรือ LINE
+	loadOIDCProvider("test", "", "")
+
+	for name := range OIDCProviders {
+		log.Println("OIDC provider enabled:", name)
+	}
+}
+
+// loadOIDCProvider อ่านค่าจาก OIDC_<NAME>_CLIENT_IDS, OIDC_<NAME>_ISSUER และ OIDC_<NAME>_JWKS_URL
+// ผู้ให้บริการจะเปิดใช้งานเมื่อกำหนด CLIENT_IDS เท่านั้น
+func loadOIDCProvider(name, defaultIssuer, defaultJWKSURL string) {
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+	var clientIDs []string
+	for _, id := range strings.Split(os.Getenv(prefix+"CLIENT_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			clientIDs = append(clientIDs, id)
+		}
+	}
+	if len(clientIDs) == 0 {
+		return
+	}
+
+	issuer := os.Getenv(prefix + "ISSUER")
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+	jwksURL := os.Getenv(prefix + "JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = defaultJWKSURL
+	}
+	if issuer == "" || jwksURL == "" {
+		log.Printf("OIDC provider %s needs %sISSUER and %sJWKS_URL", name, prefix, prefix)
+		return
+	}
+
+	OIDCProviders[name] = OIDCProvider{
+		Name:      name,
+		Issuer:    issuer,
+		JWKSURL:   jwksURL,
+		ClientIDs: clientIDs,
+	}
+}
@@ -1,21 +1,139 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-var S3Client *s3.Client
-var S3Bucket string
+// S3BlobStore ใช้ได้ทั้ง AWS S3 และบริการที่เข้ากันได้กับ S3 เช่น MinIO (กำหนด endpoint)
+type S3BlobStore struct {
+	Client  *s3.Client
+	Bucket  string
+	BaseURL string // URL ของไฟล์คือ BaseURL + "/" + key
+	// SupportsACL คือรองรับ ACL รายไฟล์ (MinIO ไม่รองรับ ใช้ bucket policy แทน)
+	SupportsACL bool
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	// ไฟล์เป็น private เสมอ client ดูรูปผ่าน SignedURL
+	_, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
 
-func LoadS3() {
-	S3Bucket = os.Getenv("AWS_BUCKET_NAME")
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NoSuchKey
+	if errors.As(err, &notFound) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj.Body, nil
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3BlobStore) MakePrivate(ctx context.Context, key string) error {
+	if !s.SupportsACL {
+		return nil
+	}
+	_, err := s.Client.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+		ACL:    types.ObjectCannedACLPrivate,
+	})
+	return err
+}
+
+func (s *S3BlobStore) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+func (s *S3BlobStore) SignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(s.Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *S3BlobStore) SignedPutURL(ctx context.Context, key, contentType string, size int64, expires time.Duration) (string, map[string]string, error) {
+	// Content-Type และ Content-Length อยู่ในลายเซ็น S3 จะปฏิเสธไฟล์ที่ชนิดหรือขนาดไม่ตรง
+	req, err := s3.NewPresignClient(s.Client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.Bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", nil, err
+	}
+	headers := map[string]string{}
+	for name, values := range req.SignedHeader {
+		// browser ตั้ง Host และ Content-Length เอง
+		if strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+	return req.URL, headers, nil
+}
+
+func (s *S3BlobStore) Size(ctx context.Context, key string) (int64, error) {
+	obj, err := s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return 0, ErrBlobNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return aws.ToInt64(obj.ContentLength), nil
+}
+
+// newS3BlobStore สร้าง store ของ AWS S3 หรือของ endpoint ที่เข้ากันได้กับ S3 เมื่อส่ง endpoint มา
+func newS3BlobStore(endpoint string) *S3BlobStore {
+	bucket := os.Getenv("AWS_BUCKET_NAME")
+	if bucket == "" {
+		log.Fatal("AWS_BUCKET_NAME is not set in .env")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" && endpoint != "" {
+		region = "us-east-1"
+	}
 
 	creds := aws.NewCredentialsCache(
 		credentials.NewStaticCredentialsProvider(
@@ -26,7 +144,7 @@ func LoadS3() {
 	)
 
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(os.Getenv("AWS_REGION")),
+		config.WithRegion(region),
 		config.WithCredentialsProvider(creds),
 	)
 
@@ -34,6 +152,21 @@ func LoadS3() {
 		log.Fatal("Cannot load AWS config:", err)
 	}
 
-	S3Client = s3.NewFromConfig(cfg)
-	log.Println("S3 Initialized")
+	store := &S3BlobStore{Bucket: bucket}
+	if endpoint == "" {
+		store.Client = s3.NewFromConfig(cfg)
+		store.BaseURL = "https://" + bucket + ".s3." + region + ".amazonaws.com"
+		store.SupportsACL = true
+	} else {
+		// MinIO ใช้ path-style: http://host:9000/bucket/key
+		store.Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		})
+		store.BaseURL = endpoint + "/" + bucket
+	}
+	if base := strings.TrimRight(os.Getenv("STORAGE_PUBLIC_URL"), "/"); base != "" {
+		store.BaseURL = base
+	}
+	return store
 }
@@ -1,25 +1,12 @@
 package controllers
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
-	"time"
-
-	"luckyPus/config"
-	"luckyPus/models"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func getLotteryCollection__() *mongo.Collection {
-	return config.Client.Database("luckyPus").Collection("lotteries")
-}
-
 type LottoAPIResponse struct {
 	Status   string `json:"status"`
 	Response struct {
@@ -50,86 +37,27 @@ func CheckUserLottery(c *gin.Context) {
 	}
 	userID, _ := primitive.ObjectIDFromHex(userIDStr.(string))
 
-	filter := bson.M{
-		"user_id": userID,
-		"status":  "ยังไม่ตรวจสอบ",
-	}
-
-	cursor, err := getLotteryCollection__().Find(context.Background(), filter)
+	latest, err := loadLatestDraw()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
-		return
-	}
-	defer cursor.Close(context.Background())
-
-	var lotteries []models.Lottery
-	if err := cursor.All(context.Background(), &lotteries); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse lotteries"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot fetch lotto API"})
 		return
 	}
 
-	resp, err := http.Get("https://lotto.api.rayriffy.com/latest")
+	// ตรวจสลากของกลุ่มที่ผู้ใช้เป็นสมาชิกไปพร้อมกัน
+	filter, err := lotteryScope(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot fetch lotto API"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
 		return
 	}
-	defer resp.Body.Close()
+	filter["status"] = statusUnchecked
+	filter["deleted_at"] = nil
 
-	var apiResult LottoAPIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResult); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot decode lotto API result"})
+	lotteries, err := checkLotteries(c, filter, latest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
 		return
 	}
 
-	for i, l := range lotteries {
-		status := "ไม่ถูกรางวัล"
-
-		// Check grand prizes
-		for _, prize := range apiResult.Response.Prizes {
-			for _, n := range prize.Number {
-				if l.Number == n {
-					status = fmt.Sprintf("ถูกรางวัล %s", prize.Name)
-				}
-			}
-		}
-
-		// Check running numbers
-		for _, running := range apiResult.Response.RunningNumbers {
-			for _, n := range running.Number {
-
-				// 2 ตัวท้าย
-				if running.ID == "runningNumberBackTwo" && len(l.Number) >= 2 &&
-					l.Number[len(l.Number)-2:] == n {
-					status = fmt.Sprintf("ถูกรางวัล %s", running.Name)
-				}
-
-				// 3 ตัวหน้า/ท้าย
-				if (running.ID == "runningNumberBackThree" || running.ID == "runningNumberFrontThree") &&
-					len(l.Number) >= 3 {
-
-					if running.ID == "runningNumberBackThree" && l.Number[len(l.Number)-3:] == n {
-						status = fmt.Sprintf("ถูกรางวัล %s", running.Name)
-					}
-
-					if running.ID == "runningNumberFrontThree" && l.Number[:3] == n {
-						status = fmt.Sprintf("ถูกรางวัล %s", running.Name)
-					}
-				}
-			}
-		}
-
-		lotteries[i].Status = status
-		lotteries[i].UpdatedAt = time.Now()
-
-		_, _ = getLotteryCollection__().UpdateOne(
-			context.Background(),
-			bson.M{"_id": l.ID},
-			bson.M{"$set": bson.M{
-				"status":     status,
-				"updated_at": time.Now(),
-			}},
-		)
-	}
-
+	presignLotteries(c.Request.Context(), lotteries)
 	c.JSON(http.StatusOK, lotteries)
 }
@@ -0,0 +1,538 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+type mergeResult struct {
+	Accounts  int `json:"accounts"`
+	Lotteries int `json:"lotteries"`
+	Devices   int `json:"devices"`
+}
+
+// isAnonymousUser รองรับบัญชีเก่าที่สร้างก่อนมีฟิลด์ anonymous ด้วย
+func isAnonymousUser(u models.User) bool {
+	if u.Anonymous {
+		return true
+	}
+	return u.Password == "" && u.Email == "" && strings.HasPrefix(u.Username, reservedUsernamePrefix)
+}
+
+// mergeAnonymousDevice รวมบัญชีไม่ระบุตัวตนเข้าสู่ intoID เมื่อผู้เรียกพิสูจน์ได้ว่าถือ refresh token ของบัญชีนั้นบนอุปกรณ์นี้
+// tokenHash คือ sha256 ของ refresh token ที่บัญชีไม่ระบุตัวตนได้รับตอนเข้าสู่ระบบด้วยอุปกรณ์
+// คืน merged=false เมื่อโทเค็นไม่ตรงหรือเจ้าของอุปกรณ์ไม่ใช่บัญชีไม่ระบุตัวตน
+func mergeAnonymousDevice(intoID primitive.ObjectID, deviceID, tokenHash string) (mergeResult, bool, error) {
+	var res mergeResult
+
+	var dev models.Device
+	err := getDeviceCollection().FindOne(context.Background(), bson.M{
+		"device_id":  deviceID,
+		"token_hash": tokenHash,
+		"user_id":    bson.M{"$ne": intoID},
+	}).Decode(&dev)
+	if err == mongo.ErrNoDocuments {
+		return res, false, nil
+	} else if err != nil {
+		return res, false, err
+	}
+
+	var from models.User
+	if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": dev.UserID}).Decode(&from); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return res, false, nil
+		}
+		return res, false, err
+	}
+	if !isAnonymousUser(from) {
+		return res, false, nil
+	}
+
+	res, err = mergeUserData(from.ID, intoID)
+	if err != nil {
+		return res, false, err
+	}
+	res.Accounts = 1
+	return res, true, nil
+}
+
+// mergeUserData ย้ายข้อมูลทั้งหมดของ fromID (สลาก อุปกรณ์ เลขที่ติดตาม การแจ้งเตือน กลุ่ม ฯลฯ) ไปยัง intoID แล้วลบบัญชี fromID
+// สลากที่งวดและเลขซ้ำกันจะรวมจำนวนใบเหมือน CreateLottery
+func mergeUserData(fromID, intoID primitive.ObjectID) (mergeResult, error) {
+	var res mergeResult
+	ctx := context.Background()
+
+	cursor, err := getLotteryCollection().Find(ctx, bson.M{"user_id": fromID})
+	if err != nil {
+		return res, err
+	}
+	var lotteries []models.Lottery
+	if err := cursor.All(ctx, &lotteries); err != nil {
+		return res, err
+	}
+
+	for _, lot := range lotteries {
+		// สลากในถังขยะและสลากของกลุ่มย้ายไปทั้งใบโดยไม่รวมจำนวนกับใบที่ยังใช้งานอยู่
+		err := mongo.ErrNoDocuments
+		var existing models.Lottery
+		if lot.DeletedAt == nil && lot.PoolID == nil {
+			err = getLotteryCollection().FindOne(ctx, bson.M{
+				"user_id":    intoID,
+				"pool_id":    nil,
+				"round":      lot.Round,
+				"number":     lot.Number,
+				"deleted_at": nil,
+			}).Decode(&existing)
+		}
+
+		if err == mongo.ErrNoDocuments {
+			_, err = getLotteryCollection().UpdateOne(ctx,
+				bson.M{"_id": lot.ID},
+				bson.M{"$set": bson.M{"user_id": intoID, "updated_at": time.Now()}},
+			)
+			if err != nil {
+				return res, err
+			}
+			res.Lotteries++
+			continue
+		} else if err != nil {
+			return res, err
+		}
+
+		qty := lot.Quantity
+		if qty <= 0 {
+			qty = 1
+		}
+		set := bson.M{"updated_at": time.Now()}
+		if moved := lotteryImages(lot); len(moved) > 0 {
+			images := append(lotteryImages(existing), moved...)
+			set["images"] = images
+			set["image_url"] = images[0].URL
+		}
+		for k, v := range mergedClaim(existing, lot) {
+			set[k] = v
+		}
+		_, err = getLotteryCollection().UpdateOne(ctx,
+			bson.M{"_id": existing.ID},
+			bson.M{"$inc": bson.M{"quantity": qty}, "$set": set},
+		)
+		if err != nil {
+			return res, err
+		}
+		if _, err := getLotteryCollection().DeleteOne(ctx, bson.M{"_id": lot.ID}); err != nil {
+			return res, err
+		}
+		res.Lotteries++
+	}
+
+	// ประวัติของสลาก การแจ้งเตือน และรูปที่รออัปโหลดย้ายตามเจ้าของใหม่
+	for _, coll := range []*mongo.Collection{
+		getLotteryEventCollection(),
+		getNotificationCollection(),
+		getImageUploadCollection(),
+	} {
+		if _, err := coll.UpdateMany(ctx,
+			bson.M{"user_id": fromID},
+			bson.M{"$set": bson.M{"user_id": intoID}},
+		); err != nil {
+			return res, err
+		}
+	}
+
+	if err := mergeWatchlist(fromID, intoID); err != nil {
+		return res, err
+	}
+	if err := mergePoolMemberships(fromID, intoID); err != nil {
+		return res, err
+	}
+
+	// อุปกรณ์ที่บัญชีปลายทางมีอยู่แล้วไม่ต้องย้าย
+	existingDevices, err := getDeviceCollection().Distinct(ctx, "device_id", bson.M{"user_id": intoID})
+	if err != nil {
+		return res, err
+	}
+	if len(existingDevices) > 0 {
+		_, err = getDeviceCollection().DeleteMany(ctx, bson.M{
+			"user_id":   fromID,
+			"device_id": bson.M{"$in": existingDevices},
+		})
+		if err != nil {
+			return res, err
+		}
+	}
+	moved, err := getDeviceCollection().UpdateMany(ctx,
+		bson.M{"user_id": fromID},
+		bson.M{"$set": bson.M{"user_id": intoID}},
+	)
+	if err != nil {
+		return res, err
+	}
+	res.Devices = int(moved.ModifiedCount)
+
+	_, _ = getIdentityCollection().UpdateMany(ctx,
+		bson.M{"user_id": fromID},
+		bson.M{"$set": bson.M{"user_id": intoID}},
+	)
+	_, _ = getUserTokenCollection().DeleteMany(ctx, bson.M{"user_id": fromID})
+	if _, err := getUserCollection().DeleteOne(ctx, bson.M{"_id": fromID}); err != nil {
+		return res, err
+	}
+
+	log.Printf("merged anonymous account %s into %s (%d lotteries, %d devices)",
+		fromID.Hex(), intoID.Hex(), res.Lotteries, res.Devices)
+	return res, nil
+}
+
+// mergedClaim คืนฟิลด์ขึ้นเงินของสลากที่รวมกัน หากใบใดบันทึกขึ้นเงินแล้วถือว่าขึ้นเงินแล้ว
+// ใช้วันขึ้นเงินที่เร็วที่สุดและรวมยอดที่ได้รับของทั้งสองใบ
+func mergedClaim(existing, moved models.Lottery) bson.M {
+	if moved.ClaimStatus != models.ClaimClaimed {
+		return nil
+	}
+	if existing.ClaimStatus != models.ClaimClaimed {
+		return bson.M{
+			"claim_status":   moved.ClaimStatus,
+			"claimed_at":     moved.ClaimedAt,
+			"claimed_amount": moved.ClaimedAmount,
+		}
+	}
+
+	set := bson.M{}
+	if moved.ClaimedAt != nil && (existing.ClaimedAt == nil || moved.ClaimedAt.Before(*existing.ClaimedAt)) {
+		set["claimed_at"] = moved.ClaimedAt
+	}
+	if moved.ClaimedAmount != nil {
+		amount := *moved.ClaimedAmount
+		if existing.ClaimedAmount != nil {
+			amount += *existing.ClaimedAmount
+		}
+		set["claimed_amount"] = amount
+	}
+	return set
+}
+
+// mergeWatchlist ย้ายเลขที่ติดตาม เลขที่บัญชีปลายทางติดตามอยู่แล้วจะถูกลบพร้อมผลของเลขนั้น
+// เพราะผลของเลขเดียวกันถูกบันทึกไว้กับบัญชีปลายทางแล้ว
+func mergeWatchlist(fromID, intoID primitive.ObjectID) error {
+	ctx := context.Background()
+
+	numbers, err := getWatchCollection().Distinct(ctx, "number", bson.M{"user_id": intoID})
+	if err != nil {
+		return err
+	}
+	if len(numbers) > 0 {
+		dupIDs, err := getWatchCollection().Distinct(ctx, "_id", bson.M{"user_id": fromID, "number": bson.M{"$in": numbers}})
+		if err != nil {
+			return err
+		}
+		if len(dupIDs) > 0 {
+			if _, err := getWatchMatchCollection().DeleteMany(ctx, bson.M{"watch_id": bson.M{"$in": dupIDs}}); err != nil {
+				return err
+			}
+			if _, err := getWatchCollection().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": dupIDs}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, coll := range []*mongo.Collection{getWatchCollection(), getWatchMatchCollection()} {
+		if _, err := coll.UpdateMany(ctx,
+			bson.M{"user_id": fromID},
+			bson.M{"$set": bson.M{"user_id": intoID}},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePoolMemberships ให้บัญชีปลายทางเป็นสมาชิกกลุ่มแทน fromID
+// กลุ่มที่บัญชีปลายทางเป็นสมาชิกอยู่แล้วจะเอา fromID ออกโดยคงข้อมูลสมาชิกเดิมของบัญชีปลายทางไว้
+func mergePoolMemberships(fromID, intoID primitive.ObjectID) error {
+	ctx := context.Background()
+
+	var into models.User
+	if err := getUserCollection().FindOne(ctx, bson.M{"_id": intoID}).Decode(&into); err != nil {
+		return err
+	}
+
+	cursor, err := getPoolCollection().Find(ctx, bson.M{"members.user_id": fromID})
+	if err != nil {
+		return err
+	}
+	var pools []models.Pool
+	if err := cursor.All(ctx, &pools); err != nil {
+		return err
+	}
+
+	for _, p := range pools {
+		set := bson.M{"updated_at": time.Now()}
+		if p.OwnerID == fromID {
+			set["owner_id"] = intoID
+		}
+
+		if _, ok := p.Member(intoID); ok {
+			if _, err := getPoolCollection().UpdateOne(ctx,
+				bson.M{"_id": p.ID},
+				bson.M{"$pull": bson.M{"members": bson.M{"user_id": fromID}}, "$set": set},
+			); err != nil {
+				return err
+			}
+			continue
+		}
+
+		set["members.$.user_id"] = intoID
+		set["members.$.username"] = into.Username
+		if _, err := getPoolCollection().UpdateOne(ctx,
+			bson.M{"_id": p.ID, "members.user_id": fromID},
+			bson.M{"$set": set},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ====================== Upgrade Anonymous Account ======================
+func UpgradeAccount(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if !isAnonymousUser(user) {
+		c.JSON(http.StatusConflict, gin.H{"error": "บัญชีนี้เป็นบัญชีเต็มรูปแบบอยู่แล้ว"})
+		return
+	}
+
+	req.Normalize()
+	if errs := req.Validate(); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง", "fields": errs})
+		return
+	}
+
+	count, err := getUserCollection().CountDocuments(context.Background(), bson.M{
+		"_id": bson.M{"$ne": user.ID},
+		"$or": []bson.M{
+			{"username": req.Username},
+			{"email": emailMatch(req.Email)},
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "เกิดข้อผิดพลาดในการตรวจสอบผู้ใช้"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ไม่สามารถใช้ชื่อผู้ใช้หรืออีเมลนี้ได้"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถอัปเกรดบัญชีได้"})
+		return
+	}
+
+	_, err = getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{
+			"username":       req.Username,
+			"email":          req.Email,
+			"password":       string(hash),
+			"anonymous":      false,
+			"email_verified": false,
+		}},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ไม่สามารถใช้ชื่อผู้ใช้หรืออีเมลนี้ได้"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถอัปเกรดบัญชีได้"})
+		return
+	}
+
+	user.Username = req.Username
+	user.Email = req.Email
+	if err := sendVerificationEmail(user); err != nil {
+		log.Println("send verification email:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": user.ID.Hex(),
+		"message": "อัปเกรดบัญชีสำเร็จ กรุณายืนยันอีเมลของคุณ",
+	})
+}
+
+// ====================== Account Deletion ======================
+
+// DeleteMe ขอลบบัญชี ข้อมูลจะถูกลบจริงเมื่อพ้นระยะผ่อนผัน (config.AccountDeletionGrace)
+// ระหว่างนั้นผู้ใช้ยกเลิกได้ด้วย POST /me/delete/cancel
+func DeleteMe(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "รหัสผ่านไม่ถูกต้อง"})
+		return
+	}
+
+	if config.AccountDeletionGrace == 0 {
+		if err := purgeUser(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถลบบัญชีได้"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "ลบบัญชีสำเร็จ"})
+		return
+	}
+
+	deleteAfter := time.Now().Add(config.AccountDeletionGrace)
+	_, err := getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"delete_after": deleteAfter}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถลบบัญชีได้"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":      "ระบบจะลบบัญชีของคุณเมื่อพ้นระยะเวลาที่กำหนด",
+		"delete_after": deleteAfter,
+	})
+}
+
+func CancelDeleteMe(c *gin.Context) {
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	_, err := getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$unset": bson.M{"delete_after": ""}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยกเลิกการลบบัญชีได้"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "ยกเลิกการลบบัญชีสำเร็จ"})
+}
+
+// purgeUser ลบผู้ใช้ อุปกรณ์ สลาก โทเค็น และรูปหลักฐานในที่เก็บไฟล์ทั้งหมด
+func purgeUser(uid primitive.ObjectID) error {
+	ctx := context.Background()
+
+	if err := leaveAllPools(uid); err != nil {
+		return err
+	}
+
+	cursor, err := getLotteryCollection().Find(ctx, bson.M{"user_id": uid, "image_url": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	var lotteries []models.Lottery
+	if err := cursor.All(ctx, &lotteries); err != nil {
+		return err
+	}
+	images := 0
+	for _, l := range lotteries {
+		for _, key := range lotteryImageKeys(l) {
+			if err := config.Blobs.Delete(context.TODO(), key); err != nil {
+				return err
+			}
+			images++
+		}
+	}
+
+	if _, err := purgeImageUploads(bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getLotteryCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getLotteryEventCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getWatchCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getWatchMatchCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getNotificationCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getDeviceCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getUserTokenCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getIdentityCollection().DeleteMany(ctx, bson.M{"user_id": uid}); err != nil {
+		return err
+	}
+	if _, err := getUserCollection().DeleteOne(ctx, bson.M{"_id": uid}); err != nil {
+		return err
+	}
+
+	log.Printf("purged account %s (%d images)", uid.Hex(), images)
+	return nil
+}
+
+// StartAccountPurgeJob ลบบัญชีที่พ้นระยะผ่อนผันแล้วเป็นระยะ
+func StartAccountPurgeJob(interval time.Duration) {
+	go func() {
+		for {
+			purgeDueAccounts()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func purgeDueAccounts() {
+	cursor, err := getUserCollection().Find(context.Background(), bson.M{
+		"delete_after": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		log.Println("purge accounts:", err)
+		return
+	}
+
+	var users []models.User
+	if err := cursor.All(context.Background(), &users); err != nil {
+		log.Println("purge accounts:", err)
+		return
+	}
+
+	for _, u := range users {
+		if err := purgeUser(u.ID); err != nil {
+			log.Println("purge account", u.ID.Hex(), err)
+		}
+	}
+}
@@ -0,0 +1,592 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+var validRoles = map[string]bool{
+	models.RoleUser:  true,
+	models.RoleAdmin: true,
+}
+
+// ฟิลด์ลับที่ไม่ส่งออกไปใน admin API
+var adminUserProjection = bson.M{
+	"password":            0,
+	"totp_secret":         0,
+	"totp_pending_secret": 0,
+	"recovery_codes":      0,
+}
+
+func parsePagination(c *gin.Context) (int64, int64) {
+	page, _ := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	return page, limit
+}
+
+// ====================== Users ======================
+func AdminListUsers(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	filter := bson.M{}
+	if q := c.Query("q"); q != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+		filter["$or"] = []bson.M{{"username": pattern}, {"email": pattern}}
+	}
+	if c.Query("disabled") == "true" {
+		filter["disabled"] = true
+	}
+	if role := c.Query("role"); role != "" {
+		filter["roles"] = role
+	}
+
+	total, err := getUserCollection().CountDocuments(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get users"})
+		return
+	}
+
+	cursor, err := getUserCollection().Find(context.Background(), filter, options.Find().
+		SetProjection(adminUserProjection).
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get users"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	users := []models.User{}
+	if err := cursor.All(context.Background(), &users); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+func AdminGetUser(c *gin.Context) {
+	uid, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	err = getUserCollection().FindOne(context.Background(), bson.M{"_id": uid},
+		options.FindOne().SetProjection(adminUserProjection),
+	).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	cursor, err := getDeviceCollection().Find(context.Background(), bson.M{"user_id": uid})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get devices"})
+		return
+	}
+	devices := []models.Device{}
+	_ = cursor.All(context.Background(), &devices)
+
+	lotteryCount, _ := getLotteryCollection().CountDocuments(context.Background(), bson.M{"user_id": uid, "deleted_at": nil})
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":          user,
+		"devices":       devices,
+		"lottery_count": lotteryCount,
+	})
+}
+
+func setUserDisabled(c *gin.Context, disabled bool) {
+	uid, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if currentID, _ := c.Get("user_id"); currentID == uid.Hex() && disabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot disable your own account"})
+		return
+	}
+
+	result, err := getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": uid},
+		bson.M{"$set": bson.M{"disabled": disabled}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update user"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if disabled {
+		// ยกเลิก refresh token ทุกอุปกรณ์ของบัญชีที่ถูกระงับ
+		_, _ = getDeviceCollection().UpdateMany(context.Background(),
+			bson.M{"user_id": uid},
+			bson.M{"$set": bson.M{"token_hash": ""}},
+		)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated", "disabled": disabled})
+}
+
+func AdminDisableUser(c *gin.Context) {
+	setUserDisabled(c, true)
+}
+
+func AdminEnableUser(c *gin.Context) {
+	setUserDisabled(c, false)
+}
+
+// AdminSetUserRoles แทนที่ role ทั้งหมดของผู้ใช้ มีผลกับคำขอถัดไปทันที
+// เพราะ AuthMiddleware อ่าน role จากฐานข้อมูลทุกครั้ง ผู้ใช้จึงไม่ต้องเข้าสู่ระบบใหม่
+func AdminSetUserRoles(c *gin.Context) {
+	uid, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input struct {
+		Roles []string `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles := []string{}
+	for _, r := range input.Roles {
+		if !validRoles[r] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + r})
+			return
+		}
+		if r != models.RoleUser {
+			roles = append(roles, r)
+		}
+	}
+
+	result, err := getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": uid},
+		bson.M{"$set": bson.M{"roles": roles}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update user"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Roles updated", "roles": models.User{Roles: roles}.EffectiveRoles()})
+}
+
+// ====================== Draws ======================
+func AdminListDraws(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	cursor, err := getDrawCollection().Find(context.Background(), bson.M{}, options.Find().
+		SetSort(bson.D{{Key: "draw_date", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get draws"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	draws := []models.Draw{}
+	if err := cursor.All(context.Background(), &draws); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse draws"})
+		return
+	}
+	c.JSON(http.StatusOK, draws)
+}
+
+// AdminSaveDraw สร้างหรือแก้ไขผลรางวัลของงวด ผลที่แก้ไขโดยผู้ดูแลจะไม่ถูก API เขียนทับ
+func AdminSaveDraw(c *gin.Context) {
+	var input struct {
+		Round          string             `json:"round" binding:"required"`
+		Prizes         []models.DrawPrize `json:"prizes"`
+		RunningNumbers []models.DrawPrize `json:"running_numbers"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	drawDate, ok := parseRoundDate(input.Round)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid round"})
+		return
+	}
+
+	draw, err := saveDraw(models.Draw{
+		DrawDate:       drawDate,
+		Date:           input.Round,
+		Prizes:         input.Prizes,
+		RunningNumbers: input.RunningNumbers,
+		Source:         drawSourceAdmin,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot save draw"})
+		return
+	}
+	c.JSON(http.StatusOK, draw)
+}
+
+// AdminRecheck ตรวจสลากใหม่กับผลที่บันทึกไว้ กรองได้ด้วย user_id และ/หรือ round (body ไม่บังคับ)
+// สลากของงวดที่ยังไม่มีผลบันทึกไว้จะคงสถานะเดิม ไม่ถูกล้างเป็น "ยังไม่ตรวจสอบ"
+func AdminRecheck(c *gin.Context) {
+	var input struct {
+		UserID string `json:"user_id"`
+		Round  string `json:"round"`
+	}
+	if err := bindOptionalJSON(c, &input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := bson.M{"deleted_at": nil}
+	if input.UserID != "" {
+		uid, err := primitive.ObjectIDFromHex(input.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		filter["user_id"] = uid
+	}
+	if input.Round != "" {
+		filter["round"] = input.Round
+	}
+
+	latest, err := loadLatestDraw()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No draw data available"})
+		return
+	}
+
+	// checkLotteries คำนวณสถานะใหม่จากผลรางวัลทั้งหมด จึงไม่ต้องล้างสถานะก่อน
+	start := time.Now()
+	lotteries, err := checkLotteries(c, filter, latest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot check lotteries"})
+		return
+	}
+
+	checked := 0
+	for _, l := range lotteries {
+		if !l.UpdatedAt.Before(start) {
+			checked++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"checked": checked,
+		"skipped": len(lotteries) - checked,
+	})
+}
+
+// ====================== Lottery Number Migration ======================
+
+type invalidNumberRow struct {
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	Round      string `json:"round"`
+	Number     string `json:"number"`
+	Normalized string `json:"normalized,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Conflict   bool   `json:"conflict"` // มีสลากเลขเดียวกันในงวดนั้นอยู่แล้ว (ของเจ้าของหรือกลุ่มเดียวกัน) จะถูกรวมจำนวนเมื่อแก้ไข
+	Action     string `json:"action,omitempty"`
+}
+
+// findInvalidNumbers หาสลากที่เลขไม่อยู่ในรูปแบบมาตรฐาน พร้อมค่าที่แปลงได้ (ถ้ามี)
+func findInvalidNumbers() ([]models.Lottery, []invalidNumberRow, error) {
+	cursor, err := getLotteryCollection().Find(context.Background(), bson.M{
+		"number": bson.M{"$not": primitive.Regex{Pattern: "^[0-9]{6}$"}},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	lotteries := []models.Lottery{}
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]invalidNumberRow, len(lotteries))
+	for i, l := range lotteries {
+		normalized, msg := normalizeLotteryNumber(l.Number)
+		rows[i] = invalidNumberRow{
+			ID:         l.ID.Hex(),
+			UserID:     l.UserID.Hex(),
+			Round:      l.Round,
+			Number:     l.Number,
+			Normalized: normalized,
+			Error:      msg,
+		}
+		if normalized != "" && l.DeletedAt == nil {
+			count, _ := getLotteryCollection().CountDocuments(context.Background(),
+				lotteryDuplicateFilter(l.UserID, l.PoolID, l.Round, normalized))
+			rows[i].Conflict = count > 0
+		}
+	}
+	return lotteries, rows, nil
+}
+
+// AdminInvalidNumbers รายงานสลากที่เลขไม่ถูกต้องจากข้อมูลเดิม ก่อนเริ่มบังคับตรวจรูปแบบเลข
+func AdminInvalidNumbers(c *gin.Context) {
+	_, rows, err := findInvalidNumbers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
+		return
+	}
+
+	fixable := 0
+	for _, r := range rows {
+		if r.Normalized != "" {
+			fixable++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     len(rows),
+		"fixable":   fixable,
+		"unfixable": len(rows) - fixable,
+		"rows":      rows,
+	})
+}
+
+// AdminFixInvalidNumbers แก้เลขที่แปลงได้ให้เป็นรูปแบบมาตรฐานและตั้งสถานะกลับเป็น "ยังไม่ตรวจสอบ"
+// ถ้ามีสลากเลขเดียวกันในงวดนั้นอยู่แล้ว จะรวมจำนวนและรูปเข้ากับใบเดิมเหมือน RestoreLottery
+// ตรวจใบซ้ำใหม่ทุกแถว เพราะแถวก่อนหน้าอาจถูกแก้เป็นเลขเดียวกันไปแล้ว
+func AdminFixInvalidNumbers(c *gin.Context) {
+	lotteries, rows, err := findInvalidNumbers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
+		return
+	}
+
+	fixed, merged, skipped := 0, 0, 0
+	for i, l := range lotteries {
+		row := &rows[i]
+		if row.Normalized == "" {
+			row.Action = "skipped"
+			skipped++
+			continue
+		}
+
+		// สลากในถังขยะไม่นับเป็นใบซ้ำ และไม่ต้องรวมกับใบอื่น
+		var existing models.Lottery
+		err := mongo.ErrNoDocuments
+		if l.DeletedAt == nil {
+			err = getLotteryCollection().FindOne(context.Background(),
+				lotteryDuplicateFilter(l.UserID, l.PoolID, l.Round, row.Normalized),
+			).Decode(&existing)
+		}
+		row.Conflict = err == nil
+		if err == nil {
+			if _, err := mergeDuplicateLottery(c, existing, l); err != nil {
+				row.Action = "failed"
+				skipped++
+				continue
+			}
+			row.Action = "merged"
+			merged++
+			continue
+		} else if err != mongo.ErrNoDocuments {
+			row.Action = "failed"
+			skipped++
+			continue
+		}
+
+		_, err = getLotteryCollection().UpdateOne(context.Background(),
+			bson.M{"_id": l.ID},
+			bson.M{
+				"$set": bson.M{
+					"number":     row.Normalized,
+					"status":     statusUnchecked,
+					"updated_at": time.Now(),
+				},
+				// เลขเปลี่ยนแล้วต้องตรวจใหม่ การขึ้นเงินเดิมใช้ไม่ได้เหมือน UpdateLottery
+				"$unset": bson.M{"claim_status": "", "claimed_at": "", "claimed_amount": ""},
+			},
+		)
+		if err != nil {
+			// มีใบเลขเดียวกันถูกสร้างขึ้นหลังการตรวจ รันซ้ำอีกครั้งจะรวมให้
+			row.Conflict = mongo.IsDuplicateKeyError(err)
+			row.Action = "failed"
+			skipped++
+			continue
+		}
+		before, after := l, l
+		after.Number, after.Status = row.Normalized, statusUnchecked
+		after.ClaimStatus, after.ClaimedAt, after.ClaimedAmount = "", nil, nil
+		recordLotteryEvent(c, models.LotteryEventUpdate, &before, &after)
+		row.Action = "fixed"
+		fixed++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fixed":   fixed,
+		"merged":  merged,
+		"skipped": skipped,
+		"rows":    rows,
+	})
+}
+
+// ====================== Image ACL Migration ======================
+
+// imageACLBatchSize คือจำนวนสลากที่ดึงมาต่อรอบ เพื่อไม่ให้ cursor เปิดค้างนานระหว่างเรียก S3
+const imageACLBatchSize = 100
+
+// imageACLFailedMax จำกัดจำนวน key ที่ล้มเหลวที่เก็บไว้แสดง ที่เกินจะนับอย่างเดียว
+const imageACLFailedMax = 100
+
+// imageACLMigration คือสถานะของงานปิดสิทธิ์ public-read ที่ทำอยู่เบื้องหลัง
+type imageACLMigration struct {
+	Running     bool       `json:"running"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Lotteries   int        `json:"lotteries"`
+	Objects     int        `json:"objects"`
+	FailedCount int        `json:"failed_count"`
+	Failed      []string   `json:"failed"`
+	Error       string     `json:"error,omitempty"`
+}
+
+var (
+	imageACLMu    sync.Mutex
+	imageACLState = imageACLMigration{Failed: []string{}}
+)
+
+// AdminMakeImagesPrivate เริ่มงานปิดสิทธิ์ public-read ของรูปหลักฐานที่อัปโหลดไว้ก่อนเปลี่ยนมาใช้ลิงก์ชั่วคราว
+// งานทำเบื้องหลังทีละชุด ดูความคืบหน้าได้จาก GET เดียวกัน รวมสลากในถังขยะด้วย เรียกซ้ำได้โดยไม่มีผลข้างเคียง
+func AdminMakeImagesPrivate(c *gin.Context) {
+	imageACLMu.Lock()
+	defer imageACLMu.Unlock()
+	if imageACLState.Running {
+		c.JSON(http.StatusConflict, gin.H{"error": "Migration is already running", "status": imageACLState})
+		return
+	}
+
+	now := time.Now()
+	imageACLState = imageACLMigration{Running: true, StartedAt: &now, Failed: []string{}}
+	go runImageACLMigration()
+
+	c.JSON(http.StatusAccepted, imageACLState)
+}
+
+// AdminImagesPrivateStatus คืนความคืบหน้าของงานล่าสุดที่เริ่มจาก AdminMakeImagesPrivate
+func AdminImagesPrivateStatus(c *gin.Context) {
+	imageACLMu.Lock()
+	defer imageACLMu.Unlock()
+	status := imageACLState
+	status.Failed = append([]string{}, imageACLState.Failed...)
+	c.JSON(http.StatusOK, status)
+}
+
+func runImageACLMigration() {
+	err := makeImagesPrivate()
+
+	imageACLMu.Lock()
+	defer imageACLMu.Unlock()
+	now := time.Now()
+	imageACLState.Running = false
+	imageACLState.FinishedAt = &now
+	if err != nil {
+		log.Println("make images private:", err)
+		imageACLState.Error = err.Error()
+	}
+}
+
+// makeImagesPrivate ไล่สลากที่มีรูปตาม _id ทีละชุด ถ้าอ่านข้อมูลไม่สำเร็จจะหยุดและคืน error
+// เพื่อไม่ให้รายงานว่าเสร็จทั้งที่ยังไม่ครบ
+func makeImagesPrivate() error {
+	ctx := context.Background()
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"image_url": bson.M{"$nin": bson.A{nil, ""}}},
+			bson.M{"images.0": bson.M{"$exists": true}},
+		},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(imageACLBatchSize).
+		SetProjection(bson.M{"image_url": 1, "images": 1, "updated_at": 1})
+
+	var lastID primitive.ObjectID
+	for {
+		if !lastID.IsZero() {
+			filter["_id"] = bson.M{"$gt": lastID}
+		}
+		cursor, err := getLotteryCollection().Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		var batch []models.Lottery
+		if err := cursor.All(ctx, &batch); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, l := range batch {
+			objects := 0
+			var failed []string
+			for _, key := range lotteryImageKeys(l) {
+				if err := config.Blobs.MakePrivate(ctx, key); err != nil {
+					log.Println("make image private:", key, err)
+					failed = append(failed, key)
+					continue
+				}
+				objects++
+			}
+
+			imageACLMu.Lock()
+			imageACLState.Lotteries++
+			imageACLState.Objects += objects
+			imageACLState.FailedCount += len(failed)
+			for _, key := range failed {
+				if len(imageACLState.Failed) < imageACLFailedMax {
+					imageACLState.Failed = append(imageACLState.Failed, key)
+				}
+			}
+			imageACLMu.Unlock()
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
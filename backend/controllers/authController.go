@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -13,25 +15,97 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 
 	"luckyPus/config"
+	"luckyPus/middleware"
 	"luckyPus/models"
 )
 
 var jwtKey []byte
 
-func init() {
-	config.LoadEnv()
-	config.ConnectDB()
+const (
+	invalidCredentialsMessage = "ชื่อผู้ใช้หรือรหัสผ่านไม่ถูกต้อง"
+	accountDisabledMessage    = "บัญชีนี้ถูกระงับการใช้งาน"
+	accessTokenTTL            = 15 * 24 * time.Hour
+)
+
+// dummyPasswordHash ใช้เทียบรหัสผ่านเมื่อไม่พบผู้ใช้ ไม่ตรงกับรหัสผ่านใด ๆ
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("luckyPus-dummy-password"), bcrypt.DefaultCost)
 
+// Init ตั้งค่าที่ controllers ใช้ ต้องเรียกหลัง config.LoadEnv และ config.ConnectDB
+// ไม่ทำใน init() เพื่อให้ go test ของแพ็กเกจนี้รันได้โดยไม่ต้องมี MongoDB
+func Init() {
 	jwtKey = []byte(config.JWTSecret)
+
+	promoteConfiguredAdmins()
+}
+
+// promoteConfiguredAdmins ให้ role admin แก่ชื่อผู้ใช้ใน ADMIN_USERNAMES ที่ยืนยันอีเมลแล้ว
+// ชื่อที่ยังไม่มีผู้ใช้หรือยังไม่ยืนยันอีเมลจะถูกข้าม กันคนที่สมัครชื่อนั้นทีหลังได้สิทธิ์ admin ตอนรีสตาร์ต
+func promoteConfiguredAdmins() {
+	if len(config.AdminUsernames) == 0 {
+		return
+	}
+	res, err := getUserCollection().UpdateMany(context.Background(),
+		bson.M{"username": bson.M{"$in": config.AdminUsernames}, "email_verified": true},
+		bson.M{"$addToSet": bson.M{"roles": models.RoleAdmin}},
+	)
+	if err != nil {
+		log.Println("promote admins:", err)
+		return
+	}
+	if int(res.MatchedCount) < len(config.AdminUsernames) {
+		log.Printf("promote admins: %d of %d ADMIN_USERNAMES skipped (not found or email not verified)",
+			len(config.AdminUsernames)-int(res.MatchedCount), len(config.AdminUsernames))
+	}
+}
+
+// signAccessToken ออก access token ที่มี role ของผู้ใช้และอุปกรณ์ที่เข้าสู่ระบบอยู่ใน claims
+func signAccessToken(user models.User, deviceID string) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": user.ID.Hex(),
+		"roles":   user.EffectiveRoles(),
+		"exp":     time.Now().Add(accessTokenTTL).Unix(),
+	}
+	if deviceID != "" {
+		claims["device_id"] = deviceID
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtKey)
 }
 
 func getUserCollection() *mongo.Collection {
 	return config.Client.Database("luckyPus").Collection("users")
 }
 
+// EnsureUserIndexes สร้าง unique index ของชื่อผู้ใช้และอีเมล (ไม่สนตัวพิมพ์เล็กใหญ่)
+// การตรวจซ้ำก่อน insert ยังอยู่เพื่อตอบข้อความที่ดี ส่วน index กันคำขอที่มาพร้อมกัน
+// ต้องเรียกหลัง NormalizeStoredEmails
+func EnsureUserIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := getUserCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// บัญชีชั่วคราวและบัญชี OAuth บางบัญชีไม่มีอีเมล
+			Keys: bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetCollation(&options.Collation{Locale: "en", Strength: 2}).
+				SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}}),
+		},
+	})
+	if err != nil {
+		log.Println("create user indexes (check for duplicate usernames or emails):", err)
+	}
+}
+
 func getDeviceCollection() *mongo.Collection {
 	return config.Client.Database("luckyPus").Collection("devices")
 }
@@ -52,48 +126,77 @@ func sha256Hex(s string) string {
 
 // ====================== Register ======================
 func Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
 		return
 	}
 
+	req.Normalize()
+	if errs := req.Validate(); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง", "fields": errs})
+		return
+	}
+
 	filter := bson.M{
 		"$or": []bson.M{
-			{"username": user.Username},
-			{"email": user.Email},
+			{"username": req.Username},
+			{"email": emailMatch(req.Email)},
 		},
 	}
 
 	var existingUser models.User
 	err := getUserCollection().FindOne(context.Background(), filter).Decode(&existingUser)
 	if err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ชื่อผู้ใช้ถูกใช้งานแล้ว"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ไม่สามารถใช้ชื่อผู้ใช้หรืออีเมลนี้ได้"})
 		return
 	} else if err != mongo.ErrNoDocuments {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "เกิดข้อผิดพลาดในการตรวจสอบผู้ใช้"})
 		return
 	}
 
-	hash, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	user.Password = string(hash)
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้างบัญชีผู้ใช้ได้"})
+		return
+	}
+
+	user := models.User{
+		Username:  req.Username,
+		Password:  string(hash),
+		Email:     req.Email,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
 
-	_, err = getUserCollection().InsertOne(context.Background(), user)
+	res, err := getUserCollection().InsertOne(context.Background(), user)
+	if mongo.IsDuplicateKeyError(err) {
+		// สมัครชื่อหรืออีเมลเดียวกันพร้อมกัน
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ไม่สามารถใช้ชื่อผู้ใช้หรืออีเมลนี้ได้"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้างบัญชีผู้ใช้ได้"})
 		return
 	}
+	user.ID = res.InsertedID.(primitive.ObjectID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "ลงทะเบียนผู้ใช้สำเร็จ"})
+	if err := sendVerificationEmail(user); err != nil {
+		log.Println("send verification email:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ลงทะเบียนผู้ใช้สำเร็จ กรุณายืนยันอีเมลของคุณ"})
 }
 
 // ====================== Login ======================
 func Login(c *gin.Context) {
 	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-		DeviceID string `json:"device_id"`
-		Name     string `json:"name"`
+		Username     string `json:"username"`
+		Password     string `json:"password"`
+		DeviceID     string `json:"device_id"`
+		Name         string `json:"name"`
+		RefreshToken string `json:"refresh_token"` // ใช้เมื่อเข้าสู่ระบบด้วยอุปกรณ์ (ดู BiometricLogin)
+		// refresh token ของบัญชีไม่ระบุตัวตนบนอุปกรณ์นี้ ส่งมาเมื่อต้องการรวมข้อมูลเข้ากับบัญชีที่เข้าสู่ระบบ
+		AnonymousRefreshToken string `json:"anonymous_refresh_token"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
@@ -101,89 +204,223 @@ func Login(c *gin.Context) {
 	}
 
 	if input.Username != "" && input.Password != "" {
+		if !middleware.CheckLoginLockout(c, input.Username) {
+			return
+		}
+
 		var user models.User
 		err := getUserCollection().FindOne(context.Background(), bson.M{"username": input.Username}).Decode(&user)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "ไม่พบบัญชีผู้ใช้"})
+		if err != nil && err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "เกิดข้อผิดพลาดในการตรวจสอบผู้ใช้"})
 			return
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "รหัสผ่านไม่ถูกต้อง"})
+		// เทียบกับ hash หลอกเมื่อไม่พบผู้ใช้ (หรือบัญชีไม่มีรหัสผ่าน) เพื่อให้เวลาตอบสนองใกล้เคียงกัน
+		// และตอบกลับข้อความเดียวกันทุกกรณี
+		hasPassword := err == nil && user.Password != ""
+		hash := []byte(user.Password)
+		if !hasPassword {
+			hash = dummyPasswordHash
+		}
+		if bcrypt.CompareHashAndPassword(hash, []byte(input.Password)) != nil || !hasPassword {
+			middleware.RecordLoginFailure(c, input.Username)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": invalidCredentialsMessage})
 			return
 		}
+		middleware.ResetLoginFailures(c, input.Username)
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"user_id": user.ID.Hex(),
-			"exp":     time.Now().Add(15 * 24 * time.Hour).Unix(),
-		})
-		accessToken, _ := token.SignedString(jwtKey)
-
-		refreshToken, _ := generateRandomToken(32)
-		refreshHash := sha256Hex(refreshToken)
-
-		if input.DeviceID != "" {
-			var dev models.Device
-			err := getDeviceCollection().FindOne(context.Background(), bson.M{
-				"user_id":   user.ID,
-				"device_id": input.DeviceID,
-			}).Decode(&dev)
+		if user.Disabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": accountDisabledMessage})
+			return
+		}
 
+		if user.MFAEnabled {
+			mfaToken, err := signMFAChallenge(user.ID, input.DeviceID, input.Name, anonymousTokenHash(input.AnonymousRefreshToken))
 			if err != nil {
-				dev = models.Device{
-					UserID:    user.ID,
-					DeviceID:  input.DeviceID,
-					Name:      input.Name,
-					TokenHash: refreshHash,
-					CreatedAt: time.Now(),
-				}
-				_, _ = getDeviceCollection().InsertOne(context.Background(), dev)
-			} else {
-				_, _ = getDeviceCollection().UpdateOne(
-					context.Background(),
-					bson.M{"_id": dev.ID},
-					bson.M{"$set": bson.M{"token_hash": refreshHash, "last_used_at": time.Now()}},
-				)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเข้าสู่ระบบได้"})
+				return
 			}
+			c.JSON(http.StatusOK, gin.H{
+				"mfa_required": true,
+				"mfa_token":    mfaToken,
+				"expires_in":   int(mfaChallengeTTL.Seconds()),
+				"message":      "กรุณากรอกรหัสยืนยันตัวตนสองชั้น",
+			})
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"user_id":       user.ID.Hex(),
-			"access_token":  accessToken,
-			"refresh_token": refreshToken,
-			"message":       "เข้าสู่ระบบสำเร็จ",
-		})
+		respondWithLoginTokens(c, user, input.DeviceID, input.Name, anonymousTokenHash(input.AnonymousRefreshToken))
 		return
 	}
 
 	if input.DeviceID != "" {
-		BiometricLogin(c)
+		deviceLogin(c, input.DeviceID, input.Name, input.RefreshToken)
 		return
 	}
 
 	c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลเข้าสู่ระบบไม่ถูกต้อง"})
 }
 
+// anonymousTokenHash คืน hash ของ refresh token บัญชีไม่ระบุตัวตนที่ส่งมาเพื่อรวมบัญชี (ว่างเมื่อไม่ได้ส่ง)
+func anonymousTokenHash(refreshToken string) string {
+	if refreshToken == "" {
+		return ""
+	}
+	return sha256Hex(refreshToken)
+}
+
+// respondWithLoginTokens ออก access/refresh token และผูก refresh token กับอุปกรณ์
+// หากส่ง refresh token ของบัญชีไม่ระบุตัวตนบนอุปกรณ์นี้มาด้วย (anonHash) ข้อมูลของบัญชีนั้นจะถูกรวมเข้ากับบัญชีนี้
+// device_id อย่างเดียวไม่พอ เพราะใครก็ส่ง device_id ของผู้อื่นมาได้
+func respondWithLoginTokens(c *gin.Context, user models.User, deviceID, name, anonHash string) {
+	var merged *mergeResult
+	if deviceID != "" && anonHash != "" {
+		res, ok, err := mergeAnonymousDevice(user.ID, deviceID, anonHash)
+		if err != nil {
+			log.Println("merge anonymous account:", err)
+		} else if ok {
+			merged = &res
+		}
+	}
+
+	accessToken, _ := signAccessToken(user, deviceID)
+
+	refreshToken, _ := generateRandomToken(32)
+	refreshHash := sha256Hex(refreshToken)
+
+	if deviceID != "" {
+		var dev models.Device
+		err := getDeviceCollection().FindOne(context.Background(), bson.M{
+			"user_id":   user.ID,
+			"device_id": deviceID,
+		}).Decode(&dev)
+
+		if err != nil {
+			dev = models.Device{
+				UserID:    user.ID,
+				DeviceID:  deviceID,
+				Name:      name,
+				TokenHash: refreshHash,
+				CreatedAt: time.Now(),
+			}
+			_, _ = getDeviceCollection().InsertOne(context.Background(), dev)
+		} else {
+			_, _ = getDeviceCollection().UpdateOne(
+				context.Background(),
+				bson.M{"_id": dev.ID},
+				bson.M{"$set": bson.M{"token_hash": refreshHash, "last_used_at": time.Now()}},
+			)
+		}
+	}
+
+	resp := gin.H{
+		"user_id":       user.ID.Hex(),
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"message":       "เข้าสู่ระบบสำเร็จ",
+	}
+	if merged != nil {
+		resp["merged"] = merged
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// errDeviceNeedsProof คือ device_id นี้ผูกกับบัญชีที่มีรหัสผ่านเท่านั้น จึงใช้ device_id อย่างเดียวเข้าสู่ระบบไม่ได้
+var errDeviceNeedsProof = errors.New("device belongs to a full account")
+
+// findAnonymousDevice หาอุปกรณ์ของบัญชีไม่ระบุตัวตนที่ใช้ deviceID นี้
+// คืน mongo.ErrNoDocuments เมื่อยังไม่มีอุปกรณ์นี้เลย
+func findAnonymousDevice(deviceID string) (models.Device, error) {
+	var devices []models.Device
+	cursor, err := getDeviceCollection().Find(context.Background(), bson.M{"device_id": deviceID})
+	if err != nil {
+		return models.Device{}, err
+	}
+	if err := cursor.All(context.Background(), &devices); err != nil {
+		return models.Device{}, err
+	}
+	if len(devices) == 0 {
+		return models.Device{}, mongo.ErrNoDocuments
+	}
+
+	for _, dev := range devices {
+		var owner models.User
+		if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": dev.UserID}).Decode(&owner); err != nil {
+			continue
+		}
+		if isAnonymousUser(owner) {
+			return dev, nil
+		}
+	}
+	return models.Device{}, errDeviceNeedsProof
+}
+
 // ====================== Biometric Login ======================
+
+// BiometricLogin เข้าสู่ระบบด้วยอุปกรณ์ บัญชีไม่ระบุตัวตนใช้ device_id อย่างเดียวได้
+// บัญชีที่มีรหัสผ่านต้องส่ง refresh_token ของอุปกรณ์นั้นมาพิสูจน์ว่าถืออุปกรณ์อยู่จริง
+// (เหมือน RefreshToken) เพราะ device_id ไม่ใช่ความลับ และเส้นทางนี้ไม่มีการตรวจ MFA
 func BiometricLogin(c *gin.Context) {
 	var input struct {
-		DeviceID string `json:"device_id" binding:"required"`
-		Name     string `json:"name"`
+		DeviceID     string `json:"device_id" binding:"required"`
+		Name         string `json:"name"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
 		return
 	}
+	deviceLogin(c, input.DeviceID, input.Name, input.RefreshToken)
+}
 
+func deviceLogin(c *gin.Context, deviceID, name, refreshToken string) {
 	var dev models.Device
-	err := getDeviceCollection().FindOne(context.Background(), bson.M{"device_id": input.DeviceID}).Decode(&dev)
+	var err error
+	if refreshToken != "" {
+		// นับความล้มเหลวต่ออุปกรณ์และ IP เหมือนรหัสผ่าน
+		lockoutKey := "device:" + deviceID
+		if !middleware.CheckLoginLockout(c, lockoutKey) {
+			return
+		}
+		err = getDeviceCollection().FindOne(context.Background(), bson.M{
+			"device_id":  deviceID,
+			"token_hash": sha256Hex(refreshToken),
+		}).Decode(&dev)
+		if err != nil {
+			middleware.RecordLoginFailure(c, lockoutKey)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "โทเค็นไม่ถูกต้องหรือหมดอายุ"})
+			return
+		}
+		middleware.ResetLoginFailures(c, lockoutKey)
+	} else {
+		dev, err = findAnonymousDevice(deviceID)
+		if err == errDeviceNeedsProof {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "อุปกรณ์นี้ผูกกับบัญชีที่มีรหัสผ่าน กรุณาเข้าสู่ระบบด้วยรหัสผ่าน"})
+			return
+		} else if err != nil && err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเข้าสู่ระบบได้"})
+			return
+		}
+	}
+
 	if err != nil {
+		suffix := deviceID
+		if len(suffix) > 6 {
+			suffix = suffix[:6]
+		}
 		user := models.User{
-			Username: "user_" + input.DeviceID[:6],
-			Password: "",
+			Username:  reservedUsernamePrefix + suffix,
+			Password:  "",
+			Anonymous: true,
+			CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
 		}
 		res, err := getUserCollection().InsertOne(context.Background(), user)
+		if mongo.IsDuplicateKeyError(err) {
+			// ต้นของ device_id ซ้ำกับบัญชีชั่วคราวอื่น ใช้ชื่อสุ่มแทน
+			random, _ := generateRandomToken(4)
+			user.Username = reservedUsernamePrefix + random
+			res, err = getUserCollection().InsertOne(context.Background(), user)
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้างบัญชีผู้ใช้ได้"})
 			return
@@ -193,22 +430,32 @@ func BiometricLogin(c *gin.Context) {
 		token, _ := generateRandomToken(32)
 		dev = models.Device{
 			UserID:    userID,
-			DeviceID:  input.DeviceID,
-			Name:      input.Name,
+			DeviceID:  deviceID,
+			Name:      name,
 			TokenHash: sha256Hex(token),
 			CreatedAt: time.Now(),
 		}
-		_, _ = getDeviceCollection().InsertOne(context.Background(), dev)
+		devRes, err := getDeviceCollection().InsertOne(context.Background(), dev)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถลงทะเบียนอุปกรณ์ได้"})
+			return
+		}
+		dev.ID = devRes.InsertedID.(primitive.ObjectID)
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": dev.UserID.Hex(),
-		"exp":     time.Now().Add(15 * 24 * time.Hour).Unix(),
-	})
-	accessToken, _ := jwtToken.SignedString(jwtKey)
+	var user models.User
+	if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": dev.UserID}).Decode(&user); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ไม่พบบัญชีผู้ใช้"})
+		return
+	}
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": accountDisabledMessage})
+		return
+	}
+	accessToken, _ := signAccessToken(user, dev.DeviceID)
 
-	refreshToken, _ := generateRandomToken(32)
-	refreshHash := sha256Hex(refreshToken)
+	newRefresh, _ := generateRandomToken(32)
+	refreshHash := sha256Hex(newRefresh)
 
 	_, _ = getDeviceCollection().UpdateOne(context.Background(),
 		bson.M{"_id": dev.ID},
@@ -218,25 +465,41 @@ func BiometricLogin(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"user_id":       dev.UserID.Hex(),
 		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+		"refresh_token": newRefresh,
 		"message":       "เข้าสู่ระบบด้วยไบโอเมตริกสำเร็จ",
 	})
 }
 
 // ====================== Device Management ======================
+
+// deviceOwner คืนผู้ใช้จาก access token ถ้าส่ง user_id มาด้วยต้องตรงกัน
+// (เดิมเชื่อ user_id จาก body ทำให้ผูกอุปกรณ์กับบัญชีของคนอื่นได้)
+func deviceOwner(c *gin.Context, bodyUserID string) (primitive.ObjectID, bool) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+	if bodyUserID != "" && bodyUserID != uid.Hex() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return uid, false
+	}
+	return uid, true
+}
+
 func RegisterDevice(c *gin.Context) {
 	var req struct {
 		DeviceID     string `json:"device_id" binding:"required"`
 		Name         string `json:"name"`
 		RefreshToken string `json:"refresh_token" binding:"required"`
-		UserID       string `json:"user_id" binding:"required"`
+		UserID       string `json:"user_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
 		return
 	}
 
-	uid, _ := primitive.ObjectIDFromHex(req.UserID)
+	uid, ok := deviceOwner(c, req.UserID)
+	if !ok {
+		return
+	}
 	hash := sha256Hex(req.RefreshToken)
 
 	dev := models.Device{
@@ -276,11 +539,12 @@ func RefreshToken(c *gin.Context) {
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": dev.UserID.Hex(),
-		"exp":     time.Now().Add(15 * 24 * time.Hour).Unix(),
-	})
-	accessToken, _ := token.SignedString(jwtKey)
+	var user models.User
+	if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": dev.UserID}).Decode(&user); err != nil || user.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "โทเค็นไม่ถูกต้องหรือหมดอายุ"})
+		return
+	}
+	accessToken, _ := signAccessToken(user, dev.DeviceID)
 
 	newRefresh, _ := generateRandomToken(32)
 	newHash := sha256Hex(newRefresh)
@@ -298,13 +562,16 @@ func RefreshToken(c *gin.Context) {
 func RevokeDevice(c *gin.Context) {
 	var req struct {
 		DeviceID string `json:"device_id" binding:"required"`
-		UserID   string `json:"user_id" binding:"required"`
+		UserID   string `json:"user_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
 		return
 	}
-	uid, _ := primitive.ObjectIDFromHex(req.UserID)
+	uid, ok := deviceOwner(c, req.UserID)
+	if !ok {
+		return
+	}
 	_, err := getDeviceCollection().DeleteOne(context.Background(), bson.M{"user_id": uid, "device_id": req.DeviceID})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเพิกถอนอุปกรณ์ได้"})
@@ -0,0 +1,443 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/models"
+)
+
+const (
+	bulkMaxRows    = 1000
+	importMaxRows  = 5000
+	importMaxBytes = 2 << 20
+)
+
+type lotteryRow struct {
+	Round    string `json:"round"`
+	Number   string `json:"number"`
+	Quantity int    `json:"quantity"`
+	lotteryMeta
+}
+
+type lotteryRowResult struct {
+	Row      int    `json:"row"`
+	Round    string `json:"round"`
+	Number   string `json:"number"`
+	Quantity int    `json:"quantity,omitempty"`
+	Result   string `json:"result"` // "created", "merged" หรือ "error"
+	ID       string `json:"id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// upsertLottery เพิ่มสลากหรือบวกจำนวนเข้ากับใบเดิมที่เลขและงวดตรงกัน
+// meta ใช้กับใบใหม่เท่านั้น ยกเว้นแท็กที่จะถูกเพิ่มให้ใบเดิมด้วย
+// poolID ที่ไม่ใช่ NilObjectID คือสลากของกลุ่ม ซึ่งรวมกับใบเดิมของกลุ่มไม่ว่าสมาชิกคนใดบันทึก
+// คืน true เมื่อเป็นการสร้างรายการใหม่
+func upsertLottery(c *gin.Context, uid, poolID primitive.ObjectID, round, number string, quantity int, meta bson.M) (models.Lottery, bool, error) {
+	if quantity <= 0 {
+		quantity = 1
+	}
+	now := time.Now()
+
+	// สลากในถังขยะไม่นับเป็นใบเดิม
+	filter := bson.M{"user_id": uid, "pool_id": nil, "round": round, "number": number, "deleted_at": nil}
+
+	setOnInsert := bson.M{
+		"status":     statusUnchecked,
+		"created_at": now,
+	}
+	if !poolID.IsZero() {
+		delete(filter, "user_id")
+		filter["pool_id"] = poolID
+		setOnInsert["user_id"] = uid
+	}
+	update := bson.M{
+		"$inc":         bson.M{"quantity": quantity},
+		"$set":         bson.M{"updated_at": now},
+		"$setOnInsert": setOnInsert,
+	}
+	for k, v := range meta {
+		if k == "tags" {
+			update["$addToSet"] = bson.M{"tags": bson.M{"$each": v}}
+		} else {
+			setOnInsert[k] = v
+		}
+	}
+
+	var before, after models.Lottery
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	err := getLotteryCollection().FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&before)
+	if mongo.IsDuplicateKeyError(err) {
+		// คำขออื่นสร้างใบเดียวกันพร้อมกัน (unique index กันไว้) ลองใหม่ครั้งเดียวจะบวกเข้าใบนั้นแทน
+		err = getLotteryCollection().FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&before)
+	}
+	created := err == mongo.ErrNoDocuments
+	if err != nil && !created {
+		return after, false, err
+	}
+
+	if err := getLotteryCollection().FindOne(context.Background(), filter).Decode(&after); err != nil {
+		return after, created, err
+	}
+
+	if created {
+		recordLotteryEvent(c, models.LotteryEventCreate, nil, &after)
+	} else {
+		recordLotteryEvent(c, models.LotteryEventMerge, &before, &after)
+	}
+	return after, created, nil
+}
+
+// lotteryDuplicateFilter คือเงื่อนไขของสลากใบเดิมที่งวดและเลขเดียวกัน แบบเดียวกับ upsertLottery
+// สลากส่วนตัวเทียบในเจ้าของเดียวกัน สลากของกลุ่มเทียบในกลุ่มเดียวกัน
+func lotteryDuplicateFilter(uid primitive.ObjectID, poolID *primitive.ObjectID, round, number string) bson.M {
+	filter := bson.M{"user_id": uid, "pool_id": nil, "round": round, "number": number, "deleted_at": nil}
+	if poolID != nil {
+		delete(filter, "user_id")
+		filter["pool_id"] = *poolID
+	}
+	return filter
+}
+
+// mergeDuplicateLottery รวมจำนวนใบ รูป และการขึ้นเงินของ lot เข้ากับ existing แล้วลบ lot
+// รูปทั้งหมดถูกย้ายไปใบเดิมจึงลบแค่ข้อมูล ไม่ลบไฟล์ (เหมือน RestoreLottery)
+// ลบ lot ก่อนเพื่อให้คำขอที่รวมพร้อมกันมีแค่คำขอเดียวที่ได้บวกจำนวน
+// คืน mongo.ErrNoDocuments เมื่อ lot ถูกรวมหรือลบไปแล้ว หรือเมื่อ existing ถูกลบไปแล้ว (lot จะถูกคืนกลับ)
+func mergeDuplicateLottery(c *gin.Context, existing, lot models.Lottery) (models.Lottery, error) {
+	var after models.Lottery
+	if err := getLotteryCollection().FindOneAndDelete(context.Background(), bson.M{"_id": lot.ID}).Decode(&lot); err != nil {
+		return after, err
+	}
+
+	qty := lot.Quantity
+	if qty <= 0 {
+		qty = 1
+	}
+	set := bson.M{"updated_at": time.Now()}
+	if moved := lotteryImages(lot); len(moved) > 0 {
+		images := append(lotteryImages(existing), moved...)
+		set["images"] = images
+		set["image_url"] = images[0].URL
+	}
+	for k, v := range mergedClaim(existing, lot) {
+		set[k] = v
+	}
+
+	err := getLotteryCollection().FindOneAndUpdate(context.Background(),
+		bson.M{"_id": existing.ID, "deleted_at": nil},
+		bson.M{"$inc": bson.M{"quantity": qty}, "$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&after)
+	if err != nil {
+		if _, insErr := getLotteryCollection().InsertOne(context.Background(), lot); insErr != nil {
+			log.Println("restore lottery after failed merge:", lot.ID.Hex(), insErr)
+		}
+		return after, err
+	}
+	recordLotteryEvent(c, models.LotteryEventMerge, &existing, &after)
+	recordLotteryEvent(c, models.LotteryEventPurge, &lot, nil)
+	return after, nil
+}
+
+// validateLotteryRow คืนข้อมูลประกอบที่จะบันทึก และข้อความผิดพลาดของแถว หรือ "" เมื่อถูกต้อง
+func validateLotteryRow(row *lotteryRow) (bson.M, string) {
+	row.Round = strings.TrimSpace(row.Round)
+	if row.Round == "" {
+		return nil, "round is required"
+	}
+	number, msg := normalizeLotteryNumber(row.Number)
+	if msg != "" {
+		return nil, msg
+	}
+	row.Number = number
+	if row.Quantity < 0 {
+		return nil, "quantity must be a positive number"
+	}
+	meta, errs := row.lotteryMeta.fields()
+	if len(errs) > 0 {
+		return nil, joinFieldErrors(errs)
+	}
+	return meta, ""
+}
+
+// saveLotteryRows บันทึกทีละแถวและรวมผลลัพธ์ แถวที่ผิดไม่ทำให้แถวอื่นล้มเหลว
+func saveLotteryRows(c *gin.Context, uid, poolID primitive.ObjectID, rows []lotteryRow, firstRow int) gin.H {
+	results := make([]lotteryRowResult, 0, len(rows))
+	created, merged, failed := 0, 0, 0
+
+	for i, row := range rows {
+		res := lotteryRowResult{Row: firstRow + i}
+		meta, msg := validateLotteryRow(&row)
+		res.Round, res.Number = row.Round, row.Number
+		if msg != "" {
+			res.Result, res.Error = "error", msg
+			failed++
+			results = append(results, res)
+			continue
+		}
+
+		l, isNew, err := upsertLottery(c, uid, poolID, row.Round, row.Number, row.Quantity, meta)
+		if err != nil {
+			res.Result, res.Error = "error", "Cannot save lottery"
+			failed++
+			results = append(results, res)
+			continue
+		}
+
+		res.ID, res.Quantity = l.ID.Hex(), l.Quantity
+		if isNew {
+			res.Result = "created"
+			created++
+		} else {
+			res.Result = "merged"
+			merged++
+		}
+		results = append(results, res)
+	}
+
+	return gin.H{
+		"created": created,
+		"merged":  merged,
+		"failed":  failed,
+		"results": results,
+	}
+}
+
+// expandNumberRange คืนเลขทุกตัวในช่วง from-to (รวมปลายทั้งสองข้าง) แบบเติม 0 ให้ครบ 6 หลัก
+func expandNumberRange(from, to string) ([]string, error) {
+	from, msgFrom := normalizeLotteryNumber(from)
+	to, msgTo := normalizeLotteryNumber(to)
+	if msgFrom != "" || msgTo != "" {
+		return nil, fmt.Errorf("range must be 6-digit numbers")
+	}
+	start, _ := strconv.Atoi(from)
+	end, _ := strconv.Atoi(to)
+	if start > end {
+		return nil, fmt.Errorf("range from must not be greater than to")
+	}
+	if end-start+1 > bulkMaxRows {
+		return nil, fmt.Errorf("range must contain at most %d numbers", bulkMaxRows)
+	}
+
+	numbers := make([]string, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		numbers = append(numbers, fmt.Sprintf("%06d", n))
+	}
+	return numbers, nil
+}
+
+// ====================== Bulk Entry ======================
+
+// BulkCreateLottery เพิ่มสลากหลายใบของงวดเดียวกัน จากรายการเลขหรือช่วงเลข (เช่น ซื้อยกชุด)
+func BulkCreateLottery(c *gin.Context) {
+	var req struct {
+		Round   string   `json:"round" binding:"required"`
+		Numbers []string `json:"numbers"`
+		Range   *struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"range"`
+		Quantity int    `json:"quantity"`
+		PoolID   string `json:"pool_id"`
+		lotteryMeta
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	poolID, ok := poolIDForTicket(c, req.PoolID, uid)
+	if !ok {
+		return
+	}
+
+	numbers := req.Numbers
+	if req.Range != nil {
+		expanded, err := expandNumberRange(req.Range.From, req.Range.To)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		numbers = append(numbers, expanded...)
+	}
+	if len(numbers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "numbers or range is required"})
+		return
+	}
+	if len(numbers) > bulkMaxRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d numbers per request", bulkMaxRows)})
+		return
+	}
+
+	rows := make([]lotteryRow, len(numbers))
+	for i, n := range numbers {
+		rows[i] = lotteryRow{Round: req.Round, Number: n, Quantity: req.Quantity, lotteryMeta: req.lotteryMeta}
+	}
+
+	c.JSON(http.StatusOK, saveLotteryRows(c, uid, poolID, rows, 1))
+}
+
+// ====================== Import ======================
+
+// ImportLottery นำเข้าสลากจากไฟล์ CSV (round,number,quantity) หรือ JSON array
+// ถ้าส่ง round มาในฟอร์ม จะใช้เป็นค่าเริ่มต้นของแถวที่ไม่ได้ระบุงวด และ pool_id เพื่อนำเข้าเป็นสลากของกลุ่ม
+func ImportLottery(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	poolID, ok := poolIDForTicket(c, strings.TrimSpace(c.PostForm("pool_id")), uid)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > importMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file must be at most 2 MB"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot open file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, importMaxBytes))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot read file"})
+		return
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+
+	var rows []lotteryRow
+	firstRow := 1
+	ext := strings.ToLower(path.Ext(fileHeader.Filename))
+	if ext == ".json" || (ext != ".csv" && bytes.HasPrefix(bytes.TrimSpace(data), []byte("["))) {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: expected an array of {round, number, quantity}"})
+			return
+		}
+	} else {
+		rows, firstRow, err = parseLotteryCSV(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid CSV: " + err.Error()})
+			return
+		}
+	}
+
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file has no rows"})
+		return
+	}
+	if len(rows) > importMaxRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d rows per file", importMaxRows)})
+		return
+	}
+
+	if defaultRound := strings.TrimSpace(c.PostForm("round")); defaultRound != "" {
+		for i := range rows {
+			if strings.TrimSpace(rows[i].Round) == "" {
+				rows[i].Round = defaultRound
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, saveLotteryRows(c, uid, poolID, rows, firstRow))
+}
+
+// parseLotteryCSV อ่าน CSV ที่มีหรือไม่มีหัวตารางก็ได้
+// ไม่มีหัวตารางจะถือว่าคอลัมน์เรียงเป็น round, number, quantity
+// หัวตารางอาจมี note, tags (คั่นด้วย ;), purchase_location, vendor และ purchased_at
+func parseLotteryCSV(data []byte) ([]lotteryRow, int, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(records) == 0 {
+		return nil, 0, nil
+	}
+
+	col := map[string]int{"round": 0, "number": 1, "quantity": 2}
+	firstRow := 1
+	header := map[string]int{}
+	for i, name := range records[0] {
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := header["number"]; ok {
+		col = map[string]int{"round": -1, "number": -1, "quantity": -1,
+			"note": -1, "tags": -1, "purchase_location": -1, "vendor": -1, "purchased_at": -1}
+		for name := range col {
+			if i, ok := header[name]; ok {
+				col[name] = i
+			}
+		}
+		records = records[1:]
+		firstRow = 2
+	}
+
+	field := func(rec []string, name string) string {
+		i := col[name]
+		if i < 0 || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	rows := make([]lotteryRow, 0, len(records))
+	for _, rec := range records {
+		row := lotteryRow{Round: field(rec, "round"), Number: field(rec, "number")}
+		row.Note = field(rec, "note")
+		row.PurchaseLocation = field(rec, "purchase_location")
+		row.Vendor = field(rec, "vendor")
+		row.PurchasedAt = field(rec, "purchased_at")
+		if tags := field(rec, "tags"); tags != "" {
+			row.Tags = strings.Split(tags, ";")
+		}
+		if q := field(rec, "quantity"); q != "" {
+			n, err := strconv.Atoi(q)
+			if err != nil {
+				// ให้ validateLotteryRow รายงานเป็นข้อผิดพลาดของแถวนี้
+				n = -1
+			}
+			row.Quantity = n
+		}
+		rows = append(rows, row)
+	}
+	return rows, firstRow, nil
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExpandNumberRange(t *testing.T) {
+	got, err := expandNumberRange("000098", "000101")
+	if err != nil {
+		t.Fatalf("expandNumberRange: %v", err)
+	}
+	want := []string{"000098", "000099", "000100", "000101"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expandNumberRange = %v, want %v", got, want)
+	}
+
+	got, err = expandNumberRange("๑๒๓๔๕๖", "123456")
+	if err != nil || !reflect.DeepEqual(got, []string{"123456"}) {
+		t.Fatalf("expandNumberRange of a single Thai-digit number = %v, %v", got, err)
+	}
+}
+
+func TestExpandNumberRangeLimit(t *testing.T) {
+	last := fmt.Sprintf("%06d", bulkMaxRows-1)
+	got, err := expandNumberRange("000000", last)
+	if err != nil || len(got) != bulkMaxRows {
+		t.Fatalf("expandNumberRange of %d numbers = %d numbers, %v", bulkMaxRows, len(got), err)
+	}
+
+	if _, err := expandNumberRange("000000", fmt.Sprintf("%06d", bulkMaxRows)); err == nil {
+		t.Fatalf("expandNumberRange accepted %d numbers", bulkMaxRows+1)
+	}
+}
+
+func TestExpandNumberRangeInvalid(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{"000010", "000009"},
+		{"12345", "123456"},
+		{"123456", "abcdef"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got, err := expandNumberRange(tt.from, tt.to); err == nil {
+			t.Errorf("expandNumberRange(%q, %q) = %v, want an error", tt.from, tt.to, got)
+		}
+	}
+}
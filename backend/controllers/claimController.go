@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/models"
+)
+
+const claimDueWithinDefault = 30 // วัน
+
+// claimView คือสลากที่ถูกรางวัลพร้อมกำหนดขึ้นเงินและยอดสุทธิ
+type claimView struct {
+	Lottery     models.Lottery `json:"lottery"`
+	PrizeLabel  string         `json:"prize_label"`
+	ClaimStatus string         `json:"claim_status"`
+	Deadline    *time.Time     `json:"deadline,omitempty"`  // วันสุดท้ายที่ขึ้นเงินได้ (สิ้นวันตามเวลาไทย)
+	DaysLeft    *int           `json:"days_left,omitempty"` // ติดลบเมื่อเลยกำหนดแล้ว
+	Payout      prizePayout    `json:"payout"`
+}
+
+// claimDeadline คือสิ้นวันครบ 2 ปีนับจากวันออกรางวัล คืน false เมื่ออ่านงวดไม่ได้
+func claimDeadline(round string) (time.Time, bool) {
+	drawDate, ok := parseRoundDate(round)
+	if !ok {
+		return time.Time{}, false
+	}
+	return drawDate.AddDate(claimPeriodYears, 0, 1).Add(-time.Nanosecond), true
+}
+
+func newClaimView(l models.Lottery, now time.Time) claimView {
+	qty := l.Quantity
+	if qty <= 0 {
+		qty = 1
+	}
+	prize, _ := prizeForStatus(l.Status)
+	v := claimView{
+		Lottery:     l,
+		PrizeLabel:  prizeLabel(l.Status),
+		ClaimStatus: models.ClaimUnclaimed,
+		Payout:      payoutFor(prize * qty),
+	}
+
+	if deadline, ok := claimDeadline(l.Round); ok {
+		v.Deadline = &deadline
+		today := time.Date(now.In(bangkok).Year(), now.In(bangkok).Month(), now.In(bangkok).Day(), 0, 0, 0, 0, bangkok)
+		last := time.Date(deadline.Year(), deadline.Month(), deadline.Day(), 0, 0, 0, 0, bangkok)
+		days := int(last.Sub(today).Hours() / 24)
+		v.DaysLeft = &days
+		if now.After(deadline) {
+			v.ClaimStatus = models.ClaimExpired
+		}
+	}
+	if l.ClaimStatus == models.ClaimClaimed {
+		v.ClaimStatus = models.ClaimClaimed
+	}
+	return v
+}
+
+func winningStatuses() []string {
+	statuses := make([]string, 0, len(prizeTable))
+	for s := range prizeTable {
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// ====================== Prize Claims ======================
+
+// ListClaims คืนสลากที่ถูกรางวัลพร้อมสถานะการขึ้นเงิน เรียงตามกำหนดขึ้นเงินที่ใกล้ที่สุด
+// ?status=unclaimed|claimed|expired และ ?due_within=<วัน> สำหรับรายการที่ใกล้หมดสิทธิ์
+func ListClaims(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	status := c.Query("status")
+	if status != "" && status != models.ClaimUnclaimed && status != models.ClaimClaimed && status != models.ClaimExpired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be unclaimed, claimed or expired"})
+		return
+	}
+
+	dueWithin := -1
+	if _, ok := c.GetQuery("due_within"); ok {
+		dueWithin = claimDueWithinDefault
+		if s := c.Query("due_within"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "due_within must be a number of days"})
+				return
+			}
+			dueWithin = n
+		}
+	}
+
+	filter, err := lotteryScope(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
+	}
+	filter["deleted_at"] = nil
+	filter["status"] = bson.M{"$in": winningStatuses()}
+
+	cursor, err := getLotteryCollection().Find(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var lotteries []models.Lottery
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse lotteries"})
+		return
+	}
+
+	presignLotteries(c.Request.Context(), lotteries)
+	now := time.Now()
+	items := []claimView{}
+	for _, l := range lotteries {
+		v := newClaimView(l, now)
+		if status != "" && v.ClaimStatus != status {
+			continue
+		}
+		// ใกล้หมดสิทธิ์คือยังไม่ขึ้นเงิน ยังไม่เลยกำหนด และเหลือไม่เกิน due_within วัน
+		if dueWithin >= 0 && (v.ClaimStatus != models.ClaimUnclaimed || v.DaysLeft == nil || *v.DaysLeft > dueWithin) {
+			continue
+		}
+		items = append(items, v)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i].Deadline, items[j].Deadline
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Before(*b)
+	})
+
+	c.JSON(http.StatusOK, items)
+}
+
+// ClaimLottery บันทึกว่าขึ้นเงินรางวัลแล้ว amount_received ไม่ส่งมาจะใช้ยอดสุทธิหลังหักอากร
+func ClaimLottery(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	var input struct {
+		ClaimedAt      string   `json:"claimed_at"` // yyyy-mm-dd หรือ RFC3339 ค่าเริ่มต้นคือวันนี้
+		AmountReceived *float64 `json:"amount_received"`
+	}
+	// ทุกฟิลด์ไม่บังคับ จึงส่ง body ว่างมาได้
+	if err := bindOptionalJSON(c, &input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lot, ok := findClaimableLottery(c, objID, uid)
+	if !ok {
+		return
+	}
+	if _, win := prizeForStatus(lot.Status); !win {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only winning tickets can be claimed"})
+		return
+	}
+
+	now := time.Now()
+	view := newClaimView(lot, now)
+
+	claimedAt := now
+	if s := strings.TrimSpace(input.ClaimedAt); s != "" {
+		t, err := parseDateParam(s, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim", "fields": gin.H{"claimed_at": "claimed_at must be yyyy-mm-dd or RFC3339"}})
+			return
+		}
+		claimedAt = t
+	}
+	if claimedAt.After(now.Add(24 * time.Hour)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim", "fields": gin.H{"claimed_at": "claimed_at must not be in the future"}})
+		return
+	}
+	if view.Deadline != nil && claimedAt.After(*view.Deadline) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The claim deadline for this ticket has passed", "deadline": view.Deadline})
+		return
+	}
+
+	amount := view.Payout.Net
+	if input.AmountReceived != nil {
+		amount = roundBaht(*input.AmountReceived)
+		if amount <= 0 || amount > float64(view.Payout.Gross) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim", "fields": gin.H{"amount_received": "amount_received must be between 0 and the gross prize"}})
+			return
+		}
+	}
+
+	_, err = getLotteryCollection().UpdateOne(context.Background(),
+		bson.M{"_id": lot.ID},
+		bson.M{"$set": bson.M{
+			"claim_status":   models.ClaimClaimed,
+			"claimed_at":     claimedAt,
+			"claimed_amount": amount,
+			"updated_at":     now,
+		}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update claim"})
+		return
+	}
+
+	after := lot
+	after.ClaimStatus = models.ClaimClaimed
+	after.ClaimedAt = &claimedAt
+	after.ClaimedAmount = &amount
+	after.UpdatedAt = now
+	recordLotteryEvent(c, models.LotteryEventClaim, &lot, &after)
+
+	presignLottery(c.Request.Context(), &after)
+	c.JSON(http.StatusOK, newClaimView(after, now))
+}
+
+// UnclaimLottery ยกเลิกการบันทึกขึ้นเงิน (เช่น กดผิด)
+func UnclaimLottery(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	lot, ok := findClaimableLottery(c, objID, uid)
+	if !ok {
+		return
+	}
+	if lot.ClaimStatus != models.ClaimClaimed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Lottery has not been claimed"})
+		return
+	}
+
+	now := time.Now()
+	_, err = getLotteryCollection().UpdateOne(context.Background(),
+		bson.M{"_id": lot.ID},
+		bson.M{
+			"$unset": bson.M{"claim_status": "", "claimed_at": "", "claimed_amount": ""},
+			"$set":   bson.M{"updated_at": now},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update claim"})
+		return
+	}
+
+	after := lot
+	after.ClaimStatus, after.ClaimedAt, after.ClaimedAmount = "", nil, nil
+	after.UpdatedAt = now
+	recordLotteryEvent(c, models.LotteryEventClaim, &lot, &after)
+
+	presignLottery(c.Request.Context(), &after)
+	c.JSON(http.StatusOK, newClaimView(after, now))
+}
+
+// findClaimableLottery โหลดสลากที่ผู้ใช้บันทึกเองหรือเป็นของกลุ่มที่ผู้ใช้เป็นสมาชิก
+func findClaimableLottery(c *gin.Context, objID, uid primitive.ObjectID) (models.Lottery, bool) {
+	var lot models.Lottery
+	filter, err := lotteryScope(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return lot, false
+	}
+	filter["_id"] = objID
+	filter["deleted_at"] = nil
+
+	if err := getLotteryCollection().FindOne(context.Background(), filter).Decode(&lot); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found"})
+		return lot, false
+	}
+	return lot, true
+}
@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+const (
+	drawSourceAPI   = "api"
+	drawSourceAdmin = "admin"
+
+	statusUnchecked = "ยังไม่ตรวจสอบ"
+	statusNoPrize   = "ไม่ถูกรางวัล"
+)
+
+var bangkok = time.FixedZone("Asia/Bangkok", 7*60*60)
+
+var thaiMonths = map[string]time.Month{
+	"มกราคม": time.January, "กุมภาพันธ์": time.February, "มีนาคม": time.March,
+	"เมษายน": time.April, "พฤษภาคม": time.May, "มิถุนายน": time.June,
+	"กรกฎาคม": time.July, "สิงหาคม": time.August, "กันยายน": time.September,
+	"ตุลาคม": time.October, "พฤศจิกายน": time.November, "ธันวาคม": time.December,
+}
+
+func getDrawCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("draws")
+}
+
+// parseRoundDate แปลงงวดเป็นวันที่ออกรางวัล รองรับ "1/10/2025", "16/10/2568" (พ.ศ.),
+// "2025-10-16" และ "16 ตุลาคม 2568" ตามที่ lotto API ส่งมา
+func parseRoundDate(round string) (time.Time, bool) {
+	round = strings.TrimSpace(round)
+	if round == "" {
+		return time.Time{}, false
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", round, bangkok); err == nil {
+		return t, true
+	}
+
+	var parts []string
+	var month time.Month
+	if strings.Contains(round, "/") {
+		parts = strings.Split(round, "/")
+		if len(parts) != 3 {
+			return time.Time{}, false
+		}
+		m, err := strconv.Atoi(parts[1])
+		if err != nil || m < 1 || m > 12 {
+			return time.Time{}, false
+		}
+		month = time.Month(m)
+	} else {
+		parts = strings.Fields(round)
+		if len(parts) != 3 {
+			return time.Time{}, false
+		}
+		m, ok := thaiMonths[parts[1]]
+		if !ok {
+			return time.Time{}, false
+		}
+		month = m
+	}
+
+	day, err := strconv.Atoi(parts[0])
+	if err != nil || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	year, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if year > 2400 {
+		year -= 543
+	}
+
+	t := time.Date(year, month, day, 0, 0, 0, 0, bangkok)
+	if t.Day() != day {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func drawFromAPI(apiResult LottoAPIResponse) (models.Draw, error) {
+	drawDate, ok := parseRoundDate(apiResult.Response.Date)
+	if !ok {
+		return models.Draw{}, fmt.Errorf("cannot parse draw date %q", apiResult.Response.Date)
+	}
+
+	draw := models.Draw{
+		DrawDate: drawDate,
+		Date:     apiResult.Response.Date,
+		Source:   drawSourceAPI,
+	}
+	for _, p := range apiResult.Response.Prizes {
+		reward, _ := strconv.Atoi(p.Reward)
+		draw.Prizes = append(draw.Prizes, models.DrawPrize{ID: p.ID, Name: p.Name, Reward: reward, Number: p.Number})
+	}
+	for _, p := range apiResult.Response.RunningNumbers {
+		reward, _ := strconv.Atoi(p.Reward)
+		draw.RunningNumbers = append(draw.RunningNumbers, models.DrawPrize{ID: p.ID, Name: p.Name, Reward: reward, Number: p.Number})
+	}
+	return draw, nil
+}
+
+func fetchLatestAPIDraw() (models.Draw, error) {
+	resp, err := http.Get("https://lotto.api.rayriffy.com/latest")
+	if err != nil {
+		return models.Draw{}, err
+	}
+	defer resp.Body.Close()
+
+	var apiResult LottoAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResult); err != nil {
+		return models.Draw{}, err
+	}
+	return drawFromAPI(apiResult)
+}
+
+// saveDraw บันทึกผลรางวัลลงคอลเลกชัน draws (หนึ่งเอกสารต่อวันออกรางวัล)
+// ผลที่ผู้ดูแลระบบแก้ไขแล้วจะไม่ถูกเขียนทับด้วยข้อมูลจาก API
+// เมื่อเป็นงวดใหม่หรือผลเปลี่ยน จะตรวจ watchlist ของผู้ใช้ทุกคนในเบื้องหลัง
+func saveDraw(draw models.Draw) (models.Draw, error) {
+	existing, found := findDrawByDate(draw.DrawDate)
+	if draw.Source == drawSourceAPI && found && existing.Source == drawSourceAdmin {
+		return existing, nil
+	}
+
+	now := time.Now()
+	var saved models.Draw
+	err := getDrawCollection().FindOneAndUpdate(context.Background(),
+		bson.M{"draw_date": draw.DrawDate},
+		bson.M{
+			"$set": bson.M{
+				"date":            draw.Date,
+				"prizes":          draw.Prizes,
+				"running_numbers": draw.RunningNumbers,
+				"source":          draw.Source,
+				"updated_at":      now,
+			},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if err != nil {
+		return saved, err
+	}
+
+	if !found || !reflect.DeepEqual(existing.Prizes, saved.Prizes) ||
+		!reflect.DeepEqual(existing.RunningNumbers, saved.RunningNumbers) {
+		go evaluateWatchlists(saved)
+	}
+	return saved, nil
+}
+
+func findDrawByDate(date time.Time) (models.Draw, bool) {
+	var draw models.Draw
+	err := getDrawCollection().FindOne(context.Background(), bson.M{"draw_date": date}).Decode(&draw)
+	return draw, err == nil
+}
+
+func findLatestStoredDraw() (models.Draw, bool) {
+	var draw models.Draw
+	err := getDrawCollection().FindOne(context.Background(), bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: "draw_date", Value: -1}}),
+	).Decode(&draw)
+	return draw, err == nil
+}
+
+// drawLockOffset คือเวลาเริ่มออกรางวัลของวันออกสลาก (14:30 น. เวลาไทย)
+// หลังจากนี้สลากของงวดนั้นลบได้อย่างเดียว แก้ไขไม่ได้
+const drawLockOffset = 14*time.Hour + 30*time.Minute
+
+// isRoundDrawn บอกว่างวดนี้ออกรางวัลแล้วหรือยัง จากผลที่บันทึกไว้หรือถึงเวลาออกรางวัลแล้ว
+// ok เป็น false เมื่ออ่านวันที่ของงวดไม่ได้
+func isRoundDrawn(round string) (drawn bool, ok bool) {
+	drawDate, ok := parseRoundDate(round)
+	if !ok {
+		return false, false
+	}
+	if _, stored := findDrawByDate(drawDate); stored {
+		return true, true
+	}
+	return !time.Now().Before(drawDate.Add(drawLockOffset)), true
+}
+
+// checkNumber คืนสถานะของเลขสลากเทียบกับผลรางวัลงวดนั้น
+func checkNumber(number string, draw models.Draw) string {
+	status := statusNoPrize
+
+	// Check grand prizes
+	for _, prize := range draw.Prizes {
+		for _, n := range prize.Number {
+			if number == n {
+				status = fmt.Sprintf("ถูกรางวัล %s", prize.Name)
+			}
+		}
+	}
+
+	// Check running numbers
+	for _, running := range draw.RunningNumbers {
+		for _, n := range running.Number {
+
+			// 2 ตัวท้าย
+			if running.ID == "runningNumberBackTwo" && len(number) >= 2 &&
+				number[len(number)-2:] == n {
+				status = fmt.Sprintf("ถูกรางวัล %s", running.Name)
+			}
+
+			// 3 ตัวหน้า/ท้าย
+			if (running.ID == "runningNumberBackThree" || running.ID == "runningNumberFrontThree") &&
+				len(number) >= 3 {
+
+				if running.ID == "runningNumberBackThree" && number[len(number)-3:] == n {
+					status = fmt.Sprintf("ถูกรางวัล %s", running.Name)
+				}
+
+				if running.ID == "runningNumberFrontThree" && number[:3] == n {
+					status = fmt.Sprintf("ถูกรางวัล %s", running.Name)
+				}
+			}
+		}
+	}
+
+	return status
+}
+
+// checkLotteries ตรวจสลากที่ตรงกับ filter กับผลรางวัลของงวดนั้น
+// สลากงวดที่ยังไม่ออกรางวัลจะคงสถานะ "ยังไม่ตรวจสอบ" ไว้
+// ส่วนสลากที่อ่านงวดไม่ได้จะตรวจกับผลงวดล่าสุดเหมือนพฤติกรรมเดิม
+func checkLotteries(c *gin.Context, filter bson.M, latest models.Draw) ([]models.Lottery, error) {
+	cursor, err := getLotteryCollection().Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var lotteries []models.Lottery
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		return nil, err
+	}
+
+	draws := map[int64]models.Draw{}
+	for i, l := range lotteries {
+		draw := latest
+		if roundDate, ok := parseRoundDate(l.Round); ok {
+			if roundDate.After(latest.DrawDate) {
+				continue
+			}
+			key := roundDate.Unix()
+			if roundDate.Equal(latest.DrawDate) {
+				draws[key] = latest
+			}
+			d, cached := draws[key]
+			if !cached {
+				var found bool
+				if d, found = findDrawByDate(roundDate); !found {
+					continue
+				}
+				draws[key] = d
+			}
+			draw = d
+		}
+
+		status := checkNumber(l.Number, draw)
+		lotteries[i].Status = status
+		lotteries[i].UpdatedAt = time.Now()
+
+		update := bson.M{"$set": bson.M{
+			"status":     status,
+			"updated_at": time.Now(),
+		}}
+		// ผลตรวจเปลี่ยน (เช่น แก้ผลรางวัลที่บันทึกผิด) การขึ้นเงินเดิมจึงใช้ไม่ได้แล้ว
+		if status != l.Status && l.ClaimStatus != "" {
+			update["$unset"] = bson.M{"claim_status": "", "claimed_at": "", "claimed_amount": ""}
+			lotteries[i].ClaimStatus, lotteries[i].ClaimedAt, lotteries[i].ClaimedAmount = "", nil, nil
+		}
+
+		_, err := getLotteryCollection().UpdateOne(
+			context.Background(),
+			bson.M{"_id": l.ID},
+			update,
+		)
+		if err == nil && status != l.Status {
+			before := l
+			recordLotteryEvent(c, models.LotteryEventCheck, &before, &lotteries[i])
+		}
+	}
+
+	return lotteries, nil
+}
+
+// loadLatestDraw ดึงผลล่าสุดจาก API แล้วบันทึกลงฐานข้อมูล
+// หาก API ใช้งานไม่ได้จะใช้ผลล่าสุดที่บันทึกไว้แทน
+func loadLatestDraw() (models.Draw, error) {
+	draw, err := fetchLatestAPIDraw()
+	if err == nil {
+		if saved, saveErr := saveDraw(draw); saveErr == nil {
+			return saved, nil
+		}
+		return draw, nil
+	}
+
+	if stored, ok := findLatestStoredDraw(); ok {
+		return stored, nil
+	}
+	return models.Draw{}, err
+}
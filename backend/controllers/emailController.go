@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+const (
+	verifyEmailTokenTTL   = 48 * time.Hour
+	resetPasswordTokenTTL = 1 * time.Hour
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+func getUserTokenCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("user_tokens")
+}
+
+// NormalizeStoredEmails แปลงอีเมลที่บันทึกไว้ก่อนมี normalizeEmail ให้เป็นตัวพิมพ์เล็ก
+// ข้ามบัญชีที่แปลงแล้วจะซ้ำกับบัญชีอื่น (ยังค้นเจอได้ด้วย emailMatch) เรียกซ้ำได้
+func NormalizeStoredEmails() {
+	ctx := context.Background()
+	cursor, err := getUserCollection().Find(ctx, bson.M{"email": primitive.Regex{Pattern: "[A-Z]|^\\s|\\s$"}})
+	if err != nil {
+		log.Println("normalize emails:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	updated, skipped := 0, 0
+	for cursor.Next(ctx) {
+		var u models.User
+		if err := cursor.Decode(&u); err != nil {
+			log.Println("normalize emails:", err)
+			continue
+		}
+		email := normalizeEmail(u.Email)
+		count, err := getUserCollection().CountDocuments(ctx, bson.M{"_id": bson.M{"$ne": u.ID}, "email": emailMatch(email)})
+		if err != nil || count > 0 {
+			log.Println("normalize emails: skip", u.ID.Hex(), "(duplicate or error)", err)
+			skipped++
+			continue
+		}
+		if _, err := getUserCollection().UpdateOne(ctx, bson.M{"_id": u.ID}, bson.M{"$set": bson.M{"email": email}}); err != nil {
+			log.Println("normalize emails:", err)
+			continue
+		}
+		updated++
+	}
+	if err := cursor.Err(); err != nil {
+		log.Println("normalize emails:", err)
+	}
+	if updated > 0 || skipped > 0 {
+		log.Printf("normalized %d stored emails (%d skipped)", updated, skipped)
+	}
+}
+
+// issueUserToken สร้างโทเค็นแบบใช้ครั้งเดียว เก็บเฉพาะ hash ลงฐานข้อมูล
+// และคืนค่าโทเค็นจริงเพื่อส่งให้ผู้ใช้ทางอีเมล
+func issueUserToken(userID primitive.ObjectID, purpose string, ttl time.Duration) (string, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	// โทเค็นเก่าที่ยังไม่ได้ใช้สำหรับวัตถุประสงค์เดียวกันจะถูกยกเลิก
+	now := time.Now()
+	_, _ = getUserTokenCollection().UpdateMany(context.Background(),
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": now}},
+	)
+
+	_, err = getUserTokenCollection().InsertOne(context.Background(), models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: sha256Hex(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken ทำเครื่องหมายว่าโทเค็นถูกใช้แล้วแบบ atomic
+// เพื่อไม่ให้โทเค็นเดียวกันถูกใช้ซ้ำได้
+func consumeUserToken(token, purpose string) (models.UserToken, error) {
+	var t models.UserToken
+	if token == "" {
+		return t, errInvalidUserToken
+	}
+
+	now := time.Now()
+	err := getUserTokenCollection().FindOneAndUpdate(context.Background(),
+		bson.M{
+			"token_hash": sha256Hex(token),
+			"purpose":    purpose,
+			"used_at":    bson.M{"$exists": false},
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return t, errInvalidUserToken
+	}
+	return t, err
+}
+
+func buildAppLink(path, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", config.AppBaseURL, path, url.QueryEscape(token))
+}
+
+func sendVerificationEmail(user models.User) error {
+	if user.Email == "" {
+		return nil
+	}
+
+	token, err := issueUserToken(user.ID, models.TokenPurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"สวัสดีคุณ %s\n\nกรุณายืนยันอีเมลของคุณโดยเปิดลิงก์ด้านล่าง (ลิงก์มีอายุ 48 ชั่วโมง)\n\n%s\n\nหากคุณไม่ได้สมัครสมาชิก Lucky Pus สามารถละเว้นอีเมลฉบับนี้ได้\n",
+		user.Username,
+		buildAppLink("/auth/email/verify", token),
+	)
+	return config.MailClient.Send(user.Email, "Lucky Pus - ยืนยันอีเมล", body)
+}
+
+func sendPasswordResetEmail(user models.User) error {
+	token, err := issueUserToken(user.ID, models.TokenPurposeResetPassword, resetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"สวัสดีคุณ %s\n\nเราได้รับคำขอรีเซ็ตรหัสผ่านของคุณ ใช้โทเค็นหรือลิงก์ด้านล่างภายใน 1 ชั่วโมง\n\nโทเค็น: %s\n%s\n\nหากคุณไม่ได้ร้องขอ สามารถละเว้นอีเมลฉบับนี้ได้ รหัสผ่านของคุณจะไม่เปลี่ยนแปลง\n",
+		user.Username,
+		token,
+		buildAppLink("/auth/password/reset", token),
+	)
+	return config.MailClient.Send(user.Email, "Lucky Pus - รีเซ็ตรหัสผ่าน", body)
+}
+
+// ====================== Email Verification ======================
+func VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" form:"token"`
+	}
+	_ = c.ShouldBind(&req)
+	if req.Token == "" {
+		req.Token = c.Query("token")
+	}
+
+	t, err := consumeUserToken(req.Token, models.TokenPurposeVerifyEmail)
+	if err == errInvalidUserToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "โทเค็นไม่ถูกต้องหรือหมดอายุ"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยืนยันอีเมลได้"})
+		return
+	}
+
+	_, err = getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": t.UserID},
+		bson.M{"$set": bson.M{
+			"email_verified":    true,
+			"email_verified_at": primitive.NewDateTimeFromTime(time.Now()),
+		}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยืนยันอีเมลได้"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ยืนยันอีเมลสำเร็จ"})
+}
+
+func ResendVerificationEmail(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	// ตอบกลับเหมือนกันทุกกรณีเพื่อไม่ให้ใช้ตรวจสอบได้ว่าอีเมลมีอยู่ในระบบหรือไม่
+	var user models.User
+	err := getUserCollection().FindOne(context.Background(), bson.M{
+		"email":          emailMatch(req.Email),
+		"email_verified": bson.M{"$ne": true},
+	}).Decode(&user)
+	if err == nil {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Println("send verification email:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "หากอีเมลนี้ยังไม่ได้ยืนยัน ระบบได้ส่งลิงก์ยืนยันไปแล้ว"})
+}
+
+// ====================== Password Reset ======================
+func ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	var user models.User
+	err := getUserCollection().FindOne(context.Background(), bson.M{"email": emailMatch(req.Email)}).Decode(&user)
+	if err == nil {
+		if err := sendPasswordResetEmail(user); err != nil {
+			log.Println("send password reset email:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "หากอีเมลนี้มีอยู่ในระบบ เราได้ส่งวิธีรีเซ็ตรหัสผ่านไปแล้ว"})
+}
+
+func ResetPassword(c *gin.Context) {
+	var req struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	// ตรวจนโยบายรหัสผ่านก่อนใช้โทเค็น เพื่อให้ผู้ใช้ลองใหม่ด้วยโทเค็นเดิมได้
+	if msg := validatePassword(req.NewPassword, "", ""); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง", "fields": gin.H{"new_password": msg}})
+		return
+	}
+
+	t, err := consumeUserToken(req.Token, models.TokenPurposeResetPassword)
+	if err == errInvalidUserToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "โทเค็นไม่ถูกต้องหรือหมดอายุ"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถรีเซ็ตรหัสผ่านได้"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถรีเซ็ตรหัสผ่านได้"})
+		return
+	}
+
+	// การรีเซ็ตผ่านอีเมลถือเป็นการยืนยันอีเมลไปในตัว
+	_, err = getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": t.UserID},
+		bson.M{"$set": bson.M{
+			"password":       string(hash),
+			"email_verified": true,
+		}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถรีเซ็ตรหัสผ่านได้"})
+		return
+	}
+
+	// ยกเลิก refresh token ของทุกอุปกรณ์ ผู้ใช้ต้องเข้าสู่ระบบใหม่
+	_, _ = getDeviceCollection().UpdateMany(context.Background(),
+		bson.M{"user_id": t.UserID},
+		bson.M{"$set": bson.M{"token_hash": ""}},
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "รีเซ็ตรหัสผ่านสำเร็จ"})
+}
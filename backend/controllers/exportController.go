@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+// ====================== Data Export ======================
+
+// ExportMyData ส่งไฟล์ ZIP ที่มีข้อมูลทั้งหมดของผู้ใช้
+// profile.json, devices.json, lotteries.json, lotteries.csv, watchlist.json และรูปหลักฐานใน images/
+func ExportMyData(c *gin.Context) {
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	cursor, err := getDeviceCollection().Find(context.Background(), bson.M{"user_id": user.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get devices"})
+		return
+	}
+	devices := []models.Device{}
+	if err := cursor.All(context.Background(), &devices); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse devices"})
+		return
+	}
+
+	cursor, err = getLotteryCollection().Find(context.Background(), bson.M{"user_id": user.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
+		return
+	}
+	lotteries := []models.Lottery{}
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse lotteries"})
+		return
+	}
+
+	cursor, err = getWatchCollection().Find(context.Background(), bson.M{"user_id": user.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get watchlist"})
+		return
+	}
+	watchlist := []models.Watch{}
+	if err := cursor.All(context.Background(), &watchlist); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse watchlist"})
+		return
+	}
+
+	filename := fmt.Sprintf("luckypus-export-%s-%s.zip", user.Username, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	defer zw.Close()
+
+	if err := writeZipJSON(zw, "profile.json", withProfileDefaults(user)); err != nil {
+		log.Println("export profile:", err)
+		return
+	}
+	if err := writeZipJSON(zw, "devices.json", devices); err != nil {
+		log.Println("export devices:", err)
+		return
+	}
+	// URL ในฐานข้อมูลเปิดไม่ได้แล้วเพราะไฟล์เป็น private จึงชี้ไปที่ไฟล์ใน images/ ของ ZIP แทน
+	lotteries = exportLotteries(lotteries)
+	if err := writeZipJSON(zw, "lotteries.json", lotteries); err != nil {
+		log.Println("export lotteries:", err)
+		return
+	}
+	if err := writeLotteriesCSV(zw, "lotteries.csv", lotteries); err != nil {
+		log.Println("export lotteries csv:", err)
+		return
+	}
+
+	if err := writeZipJSON(zw, "watchlist.json", watchlist); err != nil {
+		log.Println("export watchlist:", err)
+		return
+	}
+
+	for _, l := range lotteries {
+		// ส่งออกเฉพาะรูปต้นฉบับ ไม่รวมรูปย่อ
+		for _, img := range lotteryImages(l) {
+			if img.Key == "" {
+				continue
+			}
+			if err := writeZipBlob(zw, exportImagePath(l, img), img.Key); err != nil {
+				// รูปที่ดึงไม่ได้ไม่ควรทำให้การ export ทั้งหมดล้มเหลว
+				log.Println("export image:", img.Key, err)
+			}
+		}
+	}
+}
+
+// exportImagePath คือตำแหน่งของรูปต้นฉบับในไฟล์ ZIP
+func exportImagePath(l models.Lottery, img models.LotteryImage) string {
+	return "images/" + l.ID.Hex() + "-" + path.Base(img.Key)
+}
+
+// exportLotteries คืนสำเนาของสลากที่ URL ของรูปชี้ไปที่ไฟล์ใน ZIP
+// รูปย่อไม่ถูกส่งออกจึงไม่มี URL
+func exportLotteries(lotteries []models.Lottery) []models.Lottery {
+	out := make([]models.Lottery, len(lotteries))
+	for i, l := range lotteries {
+		images := lotteryImages(l)
+		if len(l.Images) > 0 {
+			exported := make([]models.LotteryImage, len(images))
+			for j, img := range images {
+				img.URL = ""
+				if img.Key != "" {
+					img.URL = exportImagePath(l, img)
+				}
+				if len(img.Thumbnails) > 0 {
+					thumbs := make([]models.LotteryImageThumbnail, len(img.Thumbnails))
+					for k, t := range img.Thumbnails {
+						t.URL = ""
+						thumbs[k] = t
+					}
+					img.Thumbnails = thumbs
+				}
+				exported[j] = img
+			}
+			l.Images = exported
+		}
+		l.ImageURL = ""
+		if len(images) > 0 && images[0].Key != "" {
+			l.ImageURL = exportImagePath(l, images[0])
+		}
+		out[i] = l
+	}
+	return out
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeLotteriesCSV(zw *zip.Writer, name string, lotteries []models.Lottery) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	// BOM เพื่อให้ Excel อ่านภาษาไทยได้ถูกต้อง
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "round", "number", "quantity", "status", "image_url", "created_at", "updated_at"})
+	for _, l := range lotteries {
+		_ = cw.Write([]string{
+			l.ID.Hex(),
+			spreadsheetText(l.Round),
+			l.Number,
+			strconv.Itoa(l.Quantity),
+			l.Status,
+			l.ImageURL,
+			l.CreatedAt.Format(time.RFC3339),
+			l.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeZipBlob(zw *zip.Writer, name, key string) error {
+	body, err := config.Blobs.Get(context.TODO(), key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, body)
+	return err
+}
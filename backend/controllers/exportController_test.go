@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/models"
+)
+
+func TestExportLotteries(t *testing.T) {
+	withImages := models.Lottery{
+		ID:       primitive.NewObjectID(),
+		ImageURL: "https://luckypus.s3.ap-southeast-1.amazonaws.com/lottery/a.jpg",
+		Images: []models.LotteryImage{{
+			Key:        "lottery/a.jpg",
+			URL:        "https://luckypus.s3.ap-southeast-1.amazonaws.com/lottery/a.jpg",
+			Thumbnails: []models.LotteryImageThumbnail{{Size: "small", Key: "lottery/a-small.jpg", URL: "https://luckypus.s3.ap-southeast-1.amazonaws.com/lottery/a-small.jpg"}},
+		}},
+	}
+	legacy := models.Lottery{
+		ID:       primitive.NewObjectID(),
+		ImageURL: "https://luckypus.s3.ap-southeast-1.amazonaws.com/lottery/legacy.jpg",
+	}
+	noImage := models.Lottery{ID: primitive.NewObjectID()}
+
+	out := exportLotteries([]models.Lottery{withImages, legacy, noImage})
+
+	want := "images/" + withImages.ID.Hex() + "-a.jpg"
+	if out[0].ImageURL != want || out[0].Images[0].URL != want {
+		t.Errorf("image URL = %q / %q, want %q", out[0].ImageURL, out[0].Images[0].URL, want)
+	}
+	if out[0].Images[0].Thumbnails[0].URL != "" {
+		t.Errorf("thumbnail URL = %q, want empty", out[0].Images[0].Thumbnails[0].URL)
+	}
+	if withImages.Images[0].URL == want || withImages.Images[0].Thumbnails[0].URL == "" {
+		t.Errorf("exportLotteries modified the original lottery")
+	}
+
+	if want := "images/" + legacy.ID.Hex() + "-legacy.jpg"; out[1].ImageURL != want || len(out[1].Images) != 0 {
+		t.Errorf("legacy lottery = %q %v, want %q without images", out[1].ImageURL, out[1].Images, want)
+	}
+	if out[2].ImageURL != "" {
+		t.Errorf("lottery without image = %q, want empty", out[2].ImageURL)
+	}
+}
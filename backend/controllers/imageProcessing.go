@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gen2brain/heic"
+	"github.com/gin-gonic/gin"
+
+	"luckyPus/config"
+)
+
+const (
+	lotteryImageMaxSize     = 15 << 20   // 15 MB
+	lotteryImageMaxPixels   = 24_000_000 // รูปหนึ่งใช้หน่วยความจำราว 4 byte ต่อพิกเซลตอน decode
+	lotteryImageMaxEdge     = 4096       // ด้านยาวสุดของรูปที่เก็บ รูปที่ใหญ่กว่านี้จะถูกย่อ
+	lotteryImageJPEGQuality = 85
+)
+
+// ขนาดรูปย่อที่สร้างตอนอัปโหลด (ความยาวด้านยาวสุด)
+var lotteryThumbnailSizes = []struct {
+	Name string
+	Edge int
+}{
+	{"small", 320},
+	{"medium", 1024},
+}
+
+// ชนิดไฟล์ที่ประกาศตอนขอลิงก์อัปโหลดได้ ตัวไฟล์จริงจะถูกตรวจจาก magic bytes อีกครั้ง
+var lotteryImageContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/heic": true,
+	"image/heif": true,
+}
+
+// brand ของไฟล์ HEIF ที่มือถือใช้ (HEIC)
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true, "mif1": true, "msf1": true,
+}
+
+var (
+	errImageUnsupported = errors.New("image must be a JPEG, PNG or HEIC file")
+	errImageTooLarge    = errors.New("image must be at most 15 MB")
+	errImageDimensions  = errors.New("image dimensions are too large")
+	errImageCorrupt     = errors.New("image file is corrupted")
+)
+
+// imageErrorStatus คืน HTTP status ของ error จาก processLotteryImage
+func imageErrorStatus(err error) int {
+	switch err {
+	case errImageUnsupported:
+		return http.StatusUnsupportedMediaType
+	case errImageTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func respondImageError(c *gin.Context, err error) {
+	c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+// sniffImageFormat ตรวจชนิดไฟล์จาก magic bytes ไม่เชื่อ Content-Type ที่ client ส่งมา
+func sniffImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && heifBrands[string(data[8:12])]:
+		return "heic"
+	}
+	return ""
+}
+
+type processedThumbnail struct {
+	Size          string
+	Data          []byte
+	Width, Height int
+}
+
+// processedImage คือรูปที่แปลงเป็น JPEG แล้ว ไม่มี EXIF/GPS หรือ metadata อื่นจากไฟล์ต้นฉบับ
+type processedImage struct {
+	Data          []byte
+	Width, Height int
+	Thumbnails    []processedThumbnail
+}
+
+var (
+	imageSlotsOnce sync.Once
+	imageSlots     chan struct{}
+)
+
+// acquireImageSlot จำกัดจำนวนรูปที่แปลงพร้อมกันตาม config.ImageProcessConcurrency
+// คืนฟังก์ชันสำหรับคืนสิทธิ์
+func acquireImageSlot() func() {
+	imageSlotsOnce.Do(func() {
+		n := config.ImageProcessConcurrency
+		if n <= 0 {
+			n = 1
+		}
+		imageSlots = make(chan struct{}, n)
+	})
+	imageSlots <- struct{}{}
+	return func() { <-imageSlots }
+}
+
+// decodeImage decode รูปตามชนิดที่ตรวจจาก magic bytes โดยตรวจขนาดภาพก่อน
+// กันไฟล์เล็กที่ขยายเป็นภาพขนาดมหาศาล
+func decodeImage(data []byte, format string) (image.Image, error) {
+	decodeConfig, decode := image.DecodeConfig, image.Decode
+	if format == "heic" {
+		// HEIC ใช้ libheif (WASM) ซึ่งหมุนรูปตาม irot/imir ของไฟล์ให้แล้ว
+		decodeConfig = func(r io.Reader) (image.Config, string, error) {
+			cfg, err := heic.DecodeConfig(r)
+			return cfg, format, err
+		}
+		decode = func(r io.Reader) (image.Image, string, error) {
+			img, err := heic.Decode(r)
+			return img, format, err
+		}
+	}
+
+	cfg, _, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errImageCorrupt
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > lotteryImageMaxPixels {
+		return nil, errImageDimensions
+	}
+	img, _, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errImageCorrupt
+	}
+	return img, nil
+}
+
+// processLotteryImage ตรวจไฟล์ แล้ว decode และ encode ใหม่เป็น JPEG พร้อมสร้างรูปย่อ
+// การ encode ใหม่ทิ้ง metadata ทั้งหมด จึงหมุนรูปตาม EXIF orientation ก่อนเพื่อให้รูปไม่กลับด้าน
+func processLotteryImage(data []byte) (processedImage, error) {
+	var out processedImage
+	if len(data) > lotteryImageMaxSize {
+		return out, errImageTooLarge
+	}
+	format := sniffImageFormat(data)
+	if format == "" {
+		return out, errImageUnsupported
+	}
+
+	release := acquireImageSlot()
+	defer release()
+
+	src, err := decodeImage(data, format)
+	if err != nil {
+		return out, err
+	}
+
+	// วาดลงพื้นขาว PNG ที่โปร่งใสจะได้ไม่กลายเป็นพื้นดำใน JPEG
+	b := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds(), src, b.Min, draw.Over)
+	// ย่อก่อนหมุน รูปที่หมุนจะได้เป็นสำเนาของรูปที่ย่อแล้ว ไม่ใช่รูปเต็มขนาด
+	img = fitImage(img, lotteryImageMaxEdge)
+	if format == "jpeg" {
+		img = orientImage(img, jpegOrientation(data))
+	}
+
+	if out.Data, err = encodeJPEG(img); err != nil {
+		return out, err
+	}
+	out.Width, out.Height = img.Rect.Dx(), img.Rect.Dy()
+
+	for _, size := range lotteryThumbnailSizes {
+		thumb := fitImage(img, size.Edge)
+		thumbData, err := encodeJPEG(thumb)
+		if err != nil {
+			return out, err
+		}
+		out.Thumbnails = append(out.Thumbnails, processedThumbnail{
+			Size:   size.Name,
+			Data:   thumbData,
+			Width:  thumb.Rect.Dx(),
+			Height: thumb.Rect.Dy(),
+		})
+	}
+	return out, nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: lotteryImageJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitImage ย่อรูปให้ด้านยาวสุดไม่เกิน edge โดยเฉลี่ยสีของพิกเซลที่รวมกัน (box filter) รูปที่เล็กกว่าคืนตามเดิม
+func fitImage(src *image.RGBA, edge int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if w <= edge && h <= edge {
+		return src
+	}
+	dw, dh := edge, h*edge/w
+	if h > w {
+		dw, dh = w*edge/h, edge
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		sy0, sy1 := dy*h/dh, (dy+1)*h/dh
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for dx := 0; dx < dw; dx++ {
+			sx0, sx1 := dx*w/dw, (dx+1)*w/dw
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			var r, g, b, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				i := sy*src.Stride + sx0*4
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+			j := dy*dst.Stride + dx*4
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// orientImage หมุน/กลับรูปตามค่า EXIF orientation (1-8)
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // กลับซ้ายขวา
+				sx, sy = w-1-x, y
+			case 3: // หมุน 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // กลับบนล่าง
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // หมุนตามเข็ม 90°
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // หมุนทวนเข็ม 90°
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:sy*src.Stride+sx*4+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation อ่านค่า orientation จาก EXIF (APP1) ของไฟล์ JPEG คืน 1 เมื่อไม่มีหรืออ่านไม่ได้
+func jpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // เริ่มข้อมูลภาพแล้ว EXIF ต้องอยู่ก่อนหน้านี้
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 { // Orientation, ชนิด SHORT
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
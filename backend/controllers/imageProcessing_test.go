@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func encodeTestPNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeTestJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func solidImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// exifTIFF สร้าง TIFF header ที่มี IFD เดียวและ tag Orientation
+func exifTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	b := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(b, "II")
+	} else {
+		copy(b, "MM")
+	}
+	order.PutUint16(b[2:], 42)
+	order.PutUint32(b[4:], 8)
+	order.PutUint16(b[8:], 1)
+	order.PutUint16(b[10:], 0x0112)
+	order.PutUint16(b[12:], 3) // SHORT
+	order.PutUint32(b[14:], 1)
+	order.PutUint16(b[18:], orientation)
+	return b
+}
+
+// withExif แทรก APP1 Exif ต่อจาก SOI ของไฟล์ JPEG
+func withExif(jpg []byte, tiff []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func decodeJPEG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("output is not a JPEG: %v", err)
+	}
+	return img
+}
+
+func TestProcessLotteryImageJPEG(t *testing.T) {
+	src := encodeTestJPEG(t, solidImage(2000, 1000, color.RGBA{200, 30, 30, 255}))
+	p, err := processLotteryImage(src)
+	if err != nil {
+		t.Fatalf("processLotteryImage: %v", err)
+	}
+	if p.Width != 2000 || p.Height != 1000 {
+		t.Fatalf("size = %dx%d, want 2000x1000", p.Width, p.Height)
+	}
+	decodeJPEG(t, p.Data)
+
+	want := map[string][2]int{"small": {320, 160}, "medium": {1024, 512}}
+	if len(p.Thumbnails) != len(want) {
+		t.Fatalf("thumbnails = %d, want %d", len(p.Thumbnails), len(want))
+	}
+	for _, th := range p.Thumbnails {
+		size := want[th.Size]
+		if th.Width != size[0] || th.Height != size[1] {
+			t.Errorf("%s thumbnail = %dx%d, want %dx%d", th.Size, th.Width, th.Height, size[0], size[1])
+		}
+		if b := decodeJPEG(t, th.Data).Bounds(); b.Dx() != th.Width || b.Dy() != th.Height {
+			t.Errorf("%s thumbnail decodes as %v", th.Size, b)
+		}
+	}
+}
+
+func TestProcessLotteryImageDownscales(t *testing.T) {
+	p, err := processLotteryImage(encodeTestPNG(t, image.NewGray(image.Rect(0, 0, 2*lotteryImageMaxEdge, 100))))
+	if err != nil {
+		t.Fatalf("processLotteryImage: %v", err)
+	}
+	if p.Width != lotteryImageMaxEdge || p.Height != 50 {
+		t.Fatalf("size = %dx%d, want %dx50", p.Width, p.Height, lotteryImageMaxEdge)
+	}
+}
+
+func TestProcessLotteryImageTransparentPNG(t *testing.T) {
+	p, err := processLotteryImage(encodeTestPNG(t, image.NewNRGBA(image.Rect(0, 0, 8, 8))))
+	if err != nil {
+		t.Fatalf("processLotteryImage: %v", err)
+	}
+	r, g, b, _ := decodeJPEG(t, p.Data).At(4, 4).RGBA()
+	if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Fatalf("transparent pixel = %d,%d,%d, want white", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestProcessLotteryImageStripsExifAndRotates(t *testing.T) {
+	src := withExif(encodeTestJPEG(t, solidImage(40, 20, color.Gray{128})), exifTIFF(binary.BigEndian, 6))
+	if got := jpegOrientation(src); got != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", got)
+	}
+
+	p, err := processLotteryImage(src)
+	if err != nil {
+		t.Fatalf("processLotteryImage: %v", err)
+	}
+	if p.Width != 20 || p.Height != 40 {
+		t.Fatalf("size = %dx%d, want 20x40 after rotating", p.Width, p.Height)
+	}
+	if bytes.Contains(p.Data, []byte("Exif")) {
+		t.Fatalf("output still contains EXIF")
+	}
+}
+
+func TestProcessLotteryImageHEIC(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.heic")
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+	if got := sniffImageFormat(data); got != "heic" {
+		t.Fatalf("sniffImageFormat = %q, want heic", got)
+	}
+
+	p, err := processLotteryImage(data)
+	if err != nil {
+		t.Fatalf("processLotteryImage: %v", err)
+	}
+	if b := decodeJPEG(t, p.Data).Bounds(); b.Dx() != p.Width || b.Dy() != p.Height || p.Width == 0 {
+		t.Fatalf("output %v, reported %dx%d", b, p.Width, p.Height)
+	}
+	if len(p.Thumbnails) != len(lotteryThumbnailSizes) {
+		t.Fatalf("thumbnails = %d, want %d", len(p.Thumbnails), len(lotteryThumbnailSizes))
+	}
+
+	// ไฟล์ HEIC ที่เสียต้องไม่ผ่าน
+	if _, err := processLotteryImage(data[:len(data)/2]); err != errImageCorrupt {
+		t.Fatalf("truncated HEIC: err = %v, want errImageCorrupt", err)
+	}
+}
+
+func TestProcessLotteryImageRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, errImageUnsupported},
+		{"text", []byte("hello, not an image"), errImageUnsupported},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), errImageUnsupported},
+		{"too large", append([]byte{0xFF, 0xD8, 0xFF}, make([]byte, lotteryImageMaxSize)...), errImageTooLarge},
+		{"truncated jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00}, errImageCorrupt},
+		{"huge dimensions", pngHeader(100_000, 100_000), errImageDimensions},
+		{"over the pixel limit", pngHeader(6000, 4001), errImageDimensions},
+	}
+	for _, tt := range tests {
+		if _, err := processLotteryImage(tt.data); err != tt.want {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// pngHeader สร้างไฟล์ PNG ที่มีแค่ IHDR ใช้ทดสอบการตรวจขนาดก่อน decode
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 2 // truecolor
+
+	chunk := append([]byte("IHDR"), ihdr...)
+	out := []byte("\x89PNG\r\n\x1a\n")
+	out = binary.BigEndian.AppendUint32(out, uint32(len(ihdr)))
+	out = append(out, chunk...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(chunk))
+}
+
+func TestExifOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"little endian", exifTIFF(binary.LittleEndian, 3), 3},
+		{"big endian", exifTIFF(binary.BigEndian, 8), 8},
+		{"out of range", exifTIFF(binary.BigEndian, 9), 1},
+		{"truncated", exifTIFF(binary.BigEndian, 6)[:16], 1},
+		{"bad byte order", append([]byte("XX"), exifTIFF(binary.BigEndian, 6)[2:]...), 1},
+		{"empty", nil, 1},
+	}
+	for _, tt := range tests {
+		if got := exifOrientation(tt.tiff); got != tt.want {
+			t.Errorf("%s: exifOrientation = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJPEGOrientationWithoutExif(t *testing.T) {
+	if got := jpegOrientation(encodeTestJPEG(t, image.NewGray(image.Rect(0, 0, 4, 4)))); got != 1 {
+		t.Fatalf("jpegOrientation = %d, want 1", got)
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// รูป 2x1: ซ้ายแดง ขวาน้ำเงิน
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		first       color.RGBA // พิกเซลที่ (0,0) หลังหมุน
+	}{
+		{1, 2, 1, red},
+		{2, 2, 1, blue},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},
+		{8, 1, 2, blue},
+	}
+	for _, tt := range tests {
+		got := orientImage(src, tt.orientation)
+		if got.Rect.Dx() != tt.w || got.Rect.Dy() != tt.h {
+			t.Errorf("orientation %d: size = %v, want %dx%d", tt.orientation, got.Rect, tt.w, tt.h)
+			continue
+		}
+		if c := got.RGBAAt(0, 0); c != tt.first {
+			t.Errorf("orientation %d: pixel (0,0) = %v, want %v", tt.orientation, c, tt.first)
+		}
+	}
+}
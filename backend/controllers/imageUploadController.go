@@ -0,0 +1,513 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+const (
+	imageUploadURLTTL = 15 * time.Minute
+	// ไฟล์ที่อัปโหลดแล้วแต่ไม่แจ้ง complete ภายในเวลานี้หลังลิงก์หมดอายุจะถูกลบ
+	imageUploadGrace = time.Hour
+)
+
+func getImageUploadCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("image_uploads")
+}
+
+// discardImageUpload ลบไฟล์ที่อัปโหลดค้างไว้พร้อมรายการรออัปโหลด
+func discardImageUpload(ctx context.Context, up models.ImageUpload) error {
+	if err := config.Blobs.Delete(ctx, up.Key); err != nil {
+		return err
+	}
+	_, err := getImageUploadCollection().DeleteOne(ctx, bson.M{"_id": up.ID})
+	return err
+}
+
+// ====================== Direct Image Uploads ======================
+
+// CreateImageUploadURL ออกลิงก์ PUT ชั่วคราวให้ client อัปโหลดรูปขึ้นที่เก็บไฟล์เองโดยไม่ผ่าน API
+// ลิงก์ผูกกับ content_type และ size ที่ขอ แล้วต้องเรียก POST /lottery/:id/images/complete เมื่ออัปโหลดเสร็จ
+func CreateImageUploadURL(c *gin.Context) {
+	var input struct {
+		Kind        string `json:"kind"`
+		ContentType string `json:"content_type" binding:"required"`
+		Size        int64  `json:"size" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Kind == "" {
+		input.Kind = models.LotteryImageFront
+	}
+	fields := gin.H{}
+	if !lotteryImageKinds[input.Kind] {
+		fields["kind"] = "kind must be front, back or receipt"
+	}
+	if !lotteryImageContentTypes[input.ContentType] {
+		fields["content_type"] = "content_type must be image/jpeg, image/png or image/heic"
+	}
+	if input.Size <= 0 || input.Size > lotteryImageMaxSize {
+		fields["size"] = fmt.Sprintf("size must be between 1 and %d bytes", lotteryImageMaxSize)
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload", "fields": fields})
+		return
+	}
+
+	lot, ok := findImageLottery(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	// นับลิงก์ที่ยังไม่หมดอายุรวมด้วย ไม่ให้ขอลิงก์เกินจำนวนรูปที่เพิ่มได้
+	now := time.Now()
+	pending, err := getImageUploadCollection().CountDocuments(context.Background(), bson.M{
+		"lottery_id": lot.ID,
+		"expires_at": bson.M{"$gt": now},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot create upload"})
+		return
+	}
+	if len(lotteryImages(lot))+int(pending) >= lotteryImagesMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d images per ticket", lotteryImagesMax)})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	up := models.ImageUpload{
+		ID:          primitive.NewObjectID(),
+		UserID:      uid,
+		DeviceID:    c.GetString("device_id"),
+		LotteryID:   lot.ID,
+		Kind:        input.Kind,
+		ContentType: input.ContentType,
+		Size:        input.Size,
+		Status:      models.ImageUploadPending,
+		ExpiresAt:   now.Add(imageUploadURLTTL),
+		CreatedAt:   now,
+	}
+	// ไฟล์ที่ client อัปโหลดยังไม่ผ่านการตรวจ จึงแยกไว้ก่อน แล้วย้ายไป lottery/ ตอน complete
+	up.Key = "uploads/" + up.ID.Hex()
+
+	url, headers, err := config.Blobs.SignedPutURL(c.Request.Context(), up.Key, up.ContentType, up.Size, imageUploadURLTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot create upload URL"})
+		return
+	}
+	if _, err := getImageUploadCollection().InsertOne(context.Background(), up); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot create upload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upload_id":  up.ID.Hex(),
+		"method":     http.MethodPut,
+		"url":        url,
+		"headers":    headers,
+		"expires_at": up.ExpiresAt,
+	})
+}
+
+// CompleteImageUpload ตรวจว่าไฟล์ถูกอัปโหลดครบแล้ว แล้วส่งเข้าคิวให้ worker แปลงเป็น JPEG พร้อมรูปย่อ
+// ตอบ 202 ทันทีโดยไม่อ่านไฟล์ใน API ติดตามผลได้จาก GET /lottery/:id/images/uploads/:upload_id
+// เรียกซ้ำได้ ครั้งต่อไปจะได้สถานะปัจจุบัน
+func CompleteImageUpload(c *gin.Context) {
+	var input struct {
+		UploadID string `json:"upload_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lot, up, ok := findImageUpload(c, input.UploadID)
+	if !ok {
+		return
+	}
+	if up.Status != "" && up.Status != models.ImageUploadPending {
+		respondImageUpload(c, lot, up)
+		return
+	}
+
+	size, err := config.Blobs.Size(c.Request.Context(), up.Key)
+	if err == config.ErrBlobNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "The file has not been uploaded yet"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot read upload"})
+		return
+	}
+	if size != up.Size {
+		if err := discardImageUpload(context.Background(), up); err != nil {
+			log.Println("discard image upload:", up.Key, err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file size does not match the requested size"})
+		return
+	}
+
+	now := time.Now()
+	_, err = getImageUploadCollection().UpdateOne(context.Background(),
+		bson.M{"_id": up.ID, "status": bson.M{"$in": bson.A{nil, models.ImageUploadPending}}},
+		bson.M{"$set": bson.M{"status": models.ImageUploadProcessing, "completed_at": now}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot complete upload"})
+		return
+	}
+	wakeImageWorkers()
+
+	up.Status, up.CompletedAt = models.ImageUploadProcessing, &now
+	respondImageUpload(c, lot, up)
+}
+
+// GetImageUpload คืนสถานะของการอัปโหลดตรง เมื่อแปลงเสร็จจะมีรูปที่เพิ่มเข้าสลากแล้ว
+func GetImageUpload(c *gin.Context) {
+	lot, up, ok := findImageUpload(c, c.Param("upload_id"))
+	if !ok {
+		return
+	}
+	respondImageUpload(c, lot, up)
+}
+
+// findImageUpload โหลดสลากจาก :id และรายการอัปโหลดของผู้ใช้ที่เป็นของสลากนั้น
+func findImageUpload(c *gin.Context, uploadID string) (models.Lottery, models.ImageUpload, bool) {
+	var up models.ImageUpload
+	objID, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID"})
+		return models.Lottery{}, up, false
+	}
+
+	lot, ok := findImageLottery(c, c.Param("id"))
+	if !ok {
+		return lot, up, false
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	err = getImageUploadCollection().FindOne(context.Background(),
+		bson.M{"_id": objID, "user_id": uid, "lottery_id": lot.ID},
+	).Decode(&up)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return lot, up, false
+	}
+	return lot, up, true
+}
+
+// respondImageUpload ตอบ 202 ระหว่างรอแปลง และ 200 เมื่อเสร็จหรือล้มเหลว
+func respondImageUpload(c *gin.Context, lot models.Lottery, up models.ImageUpload) {
+	status := up.Status
+	if status == "" {
+		status = models.ImageUploadPending
+	}
+	body := gin.H{"upload_id": up.ID.Hex(), "status": status}
+
+	switch status {
+	case models.ImageUploadDone:
+		presignLottery(c.Request.Context(), &lot)
+		for _, img := range lot.Images {
+			if img.ID == up.ID {
+				body["image_url"] = lot.ImageURL
+				body["image"] = img
+			}
+		}
+		c.JSON(http.StatusOK, body)
+	case models.ImageUploadFailed:
+		body["error"] = up.Error
+		c.JSON(http.StatusOK, body)
+	default:
+		c.JSON(http.StatusAccepted, body)
+	}
+}
+
+// ====================== Image Processing Worker ======================
+
+const (
+	// งานที่ worker รับไปแล้วไม่เสร็จภายในเวลานี้ (เช่น process ตายกลางคัน) จะถูกคืนให้ worker อื่น
+	imageUploadClaimTimeout = 5 * time.Minute
+	// งานที่ล้มเหลวจากที่เก็บไฟล์หรือฐานข้อมูลจะลองใหม่ได้ไม่เกินจำนวนนี้
+	imageUploadMaxAttempts = 3
+)
+
+var imageUploadWake = make(chan struct{}, 1)
+
+// wakeImageWorkers ปลุก worker ให้หยิบงานทันทีโดยไม่ต้องรอรอบถัดไป
+func wakeImageWorkers() {
+	select {
+	case imageUploadWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartImageProcessingJob เริ่ม worker แปลงรูปจากการอัปโหลดตรง จำนวนเท่ากับ IMAGE_PROCESS_CONCURRENCY
+// worker หยิบงานทุก interval หรือทันทีที่มีการแจ้ง complete งานกระจายข้ามหลาย instance ได้
+func StartImageProcessingJob(interval time.Duration) {
+	for i := 0; i < config.ImageProcessConcurrency; i++ {
+		go func() {
+			for {
+				for processNextImageUpload() {
+				}
+				select {
+				case <-imageUploadWake:
+				case <-time.After(interval):
+				}
+			}
+		}()
+	}
+}
+
+// processNextImageUpload รับงานที่รอแปลงหนึ่งงานแล้วทำให้เสร็จ คืน false เมื่อไม่มีงาน
+func processNextImageUpload() bool {
+	ctx := context.Background()
+	now := time.Now()
+
+	var up models.ImageUpload
+	err := getImageUploadCollection().FindOneAndUpdate(ctx,
+		bson.M{
+			"status": models.ImageUploadProcessing,
+			"$or": bson.A{
+				bson.M{"claimed_at": nil},
+				bson.M{"claimed_at": bson.M{"$lte": now.Add(-imageUploadClaimTimeout)}},
+			},
+		},
+		bson.M{"$set": bson.M{"claimed_at": now}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "completed_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&up)
+	if err == mongo.ErrNoDocuments {
+		return false
+	}
+	if err != nil {
+		log.Println("claim image upload:", err)
+		return false
+	}
+	// อาจยังมีงานเหลือ ให้ worker ตัวอื่นช่วยหยิบ
+	wakeImageWorkers()
+
+	reason, err := processImageUpload(ctx, up)
+	if err != nil {
+		log.Println("process image upload:", up.Key, err)
+		if up.Attempts < imageUploadMaxAttempts {
+			// คืนงานให้ลองใหม่รอบถัดไป
+			_, err := getImageUploadCollection().UpdateOne(ctx,
+				bson.M{"_id": up.ID},
+				bson.M{"$unset": bson.M{"claimed_at": ""}},
+			)
+			if err != nil {
+				log.Println("release image upload:", err)
+			}
+			return true
+		}
+		reason = "cannot process image, please upload it again"
+	}
+	if reason != "" {
+		failImageUpload(ctx, up, reason)
+	}
+	return true
+}
+
+// failImageUpload ลบไฟล์ที่อัปโหลดมาและบันทึกเหตุผลให้ client เห็นตอนดูสถานะ
+func failImageUpload(ctx context.Context, up models.ImageUpload, reason string) {
+	if err := config.Blobs.Delete(ctx, up.Key); err != nil {
+		log.Println("delete image upload:", up.Key, err)
+	}
+	_, err := getImageUploadCollection().UpdateOne(ctx,
+		bson.M{"_id": up.ID},
+		bson.M{
+			"$set":   bson.M{"status": models.ImageUploadFailed, "error": reason},
+			"$unset": bson.M{"claimed_at": ""},
+		},
+	)
+	if err != nil {
+		log.Println("fail image upload:", err)
+	}
+}
+
+// finishImageUpload ลบไฟล์ที่อัปโหลดมาหลังเก็บรูปที่แปลงแล้ว และบันทึกว่าเสร็จ
+func finishImageUpload(ctx context.Context, up models.ImageUpload) error {
+	if err := config.Blobs.Delete(ctx, up.Key); err != nil {
+		log.Println("delete image upload:", up.Key, err)
+	}
+	_, err := getImageUploadCollection().UpdateOne(ctx,
+		bson.M{"_id": up.ID},
+		bson.M{
+			"$set":   bson.M{"status": models.ImageUploadDone},
+			"$unset": bson.M{"claimed_at": "", "error": ""},
+		},
+	)
+	return err
+}
+
+// processImageUpload แปลงไฟล์ที่อัปโหลดตรงแล้วเพิ่มเป็นรูปของสลาก
+// คืน reason เมื่อรูปใช้ไม่ได้ (ไม่ต้องลองใหม่) และ err เมื่อที่เก็บไฟล์หรือฐานข้อมูลผิดพลาด
+func processImageUpload(ctx context.Context, up models.ImageUpload) (string, error) {
+	blob, err := config.Blobs.Get(ctx, up.Key)
+	if err == config.ErrBlobNotFound {
+		return "The uploaded file no longer exists", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(blob, lotteryImageMaxSize+1))
+	blob.Close()
+	if err != nil {
+		return "", err
+	}
+	processed, err := processLotteryImage(data)
+	if err != nil {
+		return err.Error(), nil
+	}
+
+	img := models.LotteryImage{ID: up.ID, Kind: up.Kind}
+	stored := false
+	// สลากอาจถูกแก้พร้อมกัน (อัปโหลดรูปอื่น จัดลำดับ) จึงโหลดใหม่แล้วลองอีกครั้ง
+	for try := 0; try < 3; try++ {
+		var lot models.Lottery
+		err := getLotteryCollection().FindOne(ctx, bson.M{"_id": up.LotteryID, "deleted_at": nil}).Decode(&lot)
+		if err != nil {
+			if stored {
+				deleteImageBlobs(ctx, img)
+			}
+			if err == mongo.ErrNoDocuments {
+				return "Lottery not found", nil
+			}
+			return "", err
+		}
+
+		images := lotteryImages(lot)
+		for _, existing := range images {
+			// รอบก่อนบันทึกลงสลากแล้วแต่ตั้งสถานะไม่สำเร็จ
+			if existing.ID == up.ID {
+				return "", finishImageUpload(ctx, up)
+			}
+		}
+		reason := ""
+		if findDuplicateImage(images, processed) != nil {
+			reason = "This image is already attached to the ticket"
+		} else if len(images) >= lotteryImagesMax {
+			reason = fmt.Sprintf("at most %d images per ticket", lotteryImagesMax)
+		}
+		if reason != "" {
+			if stored {
+				deleteImageBlobs(ctx, img)
+			}
+			return reason, nil
+		}
+
+		now := time.Now()
+		if !stored {
+			img.UploadedAt = now
+			if err := storeLotteryImage(ctx, lot.ID, &img, processed); err != nil {
+				return "", err
+			}
+			stored = true
+		}
+		images = append(images, img)
+
+		saved, err := updateLotteryImages(lot, images, now)
+		if err != nil {
+			deleteImageBlobs(ctx, img)
+			return "", err
+		}
+		if !saved {
+			continue
+		}
+
+		after := withImages(lot, images, now)
+		recordLotteryEventAs(up.UserID.Hex(), up.DeviceID, models.LotteryEventImageUpload, &lot, &after)
+		return "", finishImageUpload(ctx, up)
+	}
+
+	if stored {
+		deleteImageBlobs(ctx, img)
+	}
+	return "", fmt.Errorf("lottery %s changed during every attempt", up.LotteryID.Hex())
+}
+
+// PutLocalBlob รับไฟล์จากลิงก์ของ SignedPutURL (ใช้ตอน STORAGE_DRIVER=local เท่านั้น)
+func PutLocalBlob(c *gin.Context) {
+	store, ok := config.Blobs.(*config.LocalBlobStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	size := c.Request.ContentLength
+	if size < 0 || !store.VerifyPutSignature(key, c.Query("expires"), c.Query("signature"), c.GetHeader("Content-Type"), size) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Link is invalid or has expired"})
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, size))
+	if err != nil || int64(len(data)) != size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read file"})
+		return
+	}
+	if err := store.Put(c.Request.Context(), key, data, c.GetHeader("Content-Type")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store file"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// StartImageUploadCleanupJob ลบไฟล์ที่อัปโหลดตรงแล้วไม่ได้แจ้ง complete เป็นระยะ
+func StartImageUploadCleanupJob(interval time.Duration) {
+	go func() {
+		for {
+			// งานที่รอ worker อยู่ไม่นับ แม้ลิงก์อัปโหลดหมดอายุแล้ว
+			purged, err := purgeImageUploads(bson.M{
+				"status":     bson.M{"$ne": models.ImageUploadProcessing},
+				"expires_at": bson.M{"$lte": time.Now().Add(-imageUploadGrace)},
+			})
+			if err != nil {
+				log.Println("purge image uploads:", err)
+			} else if purged > 0 {
+				log.Printf("purged %d abandoned image uploads", purged)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func purgeImageUploads(filter bson.M) (int, error) {
+	ctx := context.Background()
+	cursor, err := getImageUploadCollection().Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var uploads []models.ImageUpload
+	if err := cursor.All(ctx, &uploads); err != nil {
+		return 0, err
+	}
+	for i, up := range uploads {
+		if err := discardImageUpload(ctx, up); err != nil {
+			return i, err
+		}
+	}
+	return len(uploads), nil
+}
@@ -1,18 +1,11 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -26,143 +19,7 @@ func getLotteryCollection() *mongo.Collection {
 	return config.Client.Database("luckyPus").Collection("lotteries")
 }
 
-var s3Client *s3.Client
-
-func init() {
-	config.LoadEnv()
-	config.ConnectDB()
-	config.LoadS3()
-}
-
-func InitS3(client *s3.Client) {
-	s3Client = client
-}
-
-func UploadLotteryImage(c *gin.Context) {
-	lotteryID := c.PostForm("lottery_id")
-	if lotteryID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "lottery_id is required"})
-		return
-	}
-
-	fileHeader, err := c.FormFile("image")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
-		return
-	}
-
-	file, err := fileHeader.Open()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot open file"})
-		return
-	}
-	defer file.Close()
-
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot read file"})
-		return
-	}
-
-	s3Key := fmt.Sprintf("lottery/%s-%d.jpg", lotteryID, time.Now().Unix())
-
-	_, err = config.S3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(config.S3Bucket),
-		Key:         aws.String(s3Key),
-		Body:        bytes.NewReader(fileBytes),
-		ContentType: aws.String(fileHeader.Header.Get("Content-Type")),
-		ACL:         types.ObjectCannedACLPublicRead,
-	})
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload to S3", "detail": err.Error()})
-		return
-	}
-
-	imageURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s",
-		config.S3Bucket,
-		os.Getenv("AWS_REGION"),
-		s3Key,
-	)
-
-	collection := getLotteryCollection()
-	objID, _ := primitive.ObjectIDFromHex(lotteryID)
-
-	userID, _ := c.Get("user_id")
-	uid, _ := primitive.ObjectIDFromHex(userID.(string))
-
-	update := bson.M{
-		"$set": bson.M{
-			"image_url":  imageURL,
-			"updated_at": time.Now(),
-		},
-	}
-
-	_, err = collection.UpdateOne(context.Background(),
-		bson.M{"_id": objID, "user_id": uid},
-		update,
-	)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot update lottery"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "image uploaded successfully",
-		"image_url": imageURL,
-	})
-}
-
-func DeleteLotteryImage(c *gin.Context) {
-	id := c.Param("id")
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery ID"})
-		return
-	}
-
-	userID, _ := c.Get("user_id")
-	uid, _ := primitive.ObjectIDFromHex(userID.(string))
-
-	collection := getLotteryCollection()
-
-	var lot models.Lottery
-	err = collection.FindOne(context.Background(),
-		bson.M{"_id": objID, "user_id": uid},
-	).Decode(&lot)
-
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found"})
-		return
-	}
-
-	if lot.ImageURL != "" {
-		s3Key := extractKeyFromURL(lot.ImageURL)
-
-		_, _ = config.S3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-			Bucket: aws.String(config.S3Bucket),
-			Key:    aws.String(s3Key),
-		})
-	}
-
-	update := bson.M{
-		"$unset": bson.M{"image_url": ""},
-		"$set":   bson.M{"updated_at": time.Now()},
-	}
-
-	_, err = collection.UpdateOne(context.Background(),
-		bson.M{"_id": objID, "user_id": uid},
-		update)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
-}
-
+// extractKeyFromURL อ่าน object key จาก image_url แบบเดิมที่เป็น URL ของ AWS S3
 func extractKeyFromURL(url string) string {
 	parts := strings.Split(url, ".amazonaws.com/")
 	if len(parts) == 2 {
@@ -172,7 +29,13 @@ func extractKeyFromURL(url string) string {
 }
 
 func CreateLottery(c *gin.Context) {
-	var l models.Lottery
+	var l struct {
+		Round    string `json:"round"`
+		Number   string `json:"number"`
+		Quantity int    `json:"quantity"`
+		PoolID   string `json:"pool_id"` // ว่างคือสลากส่วนตัว
+		lotteryMeta
+	}
 	if err := c.ShouldBindJSON(&l); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -184,44 +47,40 @@ func CreateLottery(c *gin.Context) {
 		return
 	}
 	uid, _ := primitive.ObjectIDFromHex(userID.(string))
-	l.UserID = uid
 
-	if l.Quantity <= 0 {
-		l.Quantity = 1
+	number, msg := normalizeLotteryNumber(l.Number)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery number", "fields": gin.H{"number": msg}})
+		return
 	}
+	l.Number = number
 
-	filter := bson.M{"user_id": uid, "round": l.Round, "number": l.Number}
-	var existing models.Lottery
-	err := getLotteryCollection().FindOne(context.Background(), filter).Decode(&existing)
-
-	if err == nil {
-		update := bson.M{
-			"$inc": bson.M{"quantity": l.Quantity},
-			"$set": bson.M{"updated_at": time.Now()},
-		}
-		_, err := getLotteryCollection().UpdateOne(context.Background(), filter, update)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update lottery quantity"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "Lottery quantity updated successfully"})
+	meta, errs := l.lotteryMeta.fields()
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery details", "fields": errs})
 		return
 	}
 
-	l.CreatedAt = time.Now()
-	l.UpdatedAt = time.Now()
-	l.Status = "ยังไม่ตรวจสอบ"
+	poolID, ok := poolIDForTicket(c, l.PoolID, uid)
+	if !ok {
+		return
+	}
 
-	result, err := getLotteryCollection().InsertOne(context.Background(), l)
+	saved, created, err := upsertLottery(c, uid, poolID, l.Round, l.Number, l.Quantity, meta)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot create lottery"})
 		return
 	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"message": "Lottery quantity updated successfully"})
+		return
+	}
 
-	l.ID = result.InsertedID.(primitive.ObjectID)
-	c.JSON(http.StatusOK, l)
+	c.JSON(http.StatusOK, saved)
 }
 
+// GetLotteries คืนสลากของผู้ใช้ทีละหน้า (body ยังเป็น array เหมือนเดิม)
+// ถ้ายังมีหน้าถัดไปจะส่ง cursor มาใน header X-Next-Cursor และใช้ ?pool_id= เพื่อดูสลากของกลุ่ม
 func GetLotteries(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -230,18 +89,47 @@ func GetLotteries(c *gin.Context) {
 	}
 	uid, _ := primitive.ObjectIDFromHex(userID.(string))
 
-	cursor, err := getLotteryCollection().Find(context.Background(), bson.M{"user_id": uid})
+	q, errs := parseLotteryQuery(c, uid)
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query", "fields": errs})
+		return
+	}
+	if raw := c.Query("pool_id"); raw != "" {
+		poolID, ok := poolIDForTicket(c, raw, uid)
+		if !ok {
+			return
+		}
+		delete(q.Filter, "user_id")
+		q.Filter["pool_id"] = poolID
+	}
+
+	filter, opts, err := q.findOptions()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query", "fields": gin.H{"cursor": "invalid cursor"}})
+		return
+	}
+
+	cursor, err := getLotteryCollection().Find(context.Background(), filter, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
 		return
 	}
 	defer cursor.Close(context.Background())
 
-	var lotteries []models.Lottery
+	lotteries := []models.Lottery{}
 	if err := cursor.All(context.Background(), &lotteries); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse lotteries"})
 		return
 	}
+
+	if q.Limit > 0 && int64(len(lotteries)) > q.Limit {
+		lotteries = lotteries[:q.Limit]
+		c.Header("X-Next-Cursor", encodeLotteryCursor(q.SortField, lotteries[len(lotteries)-1]))
+		c.Header("X-Has-More", "true")
+	} else {
+		c.Header("X-Has-More", "false")
+	}
+	presignLotteries(c.Request.Context(), lotteries)
 	c.JSON(http.StatusOK, lotteries)
 }
 
@@ -264,49 +152,174 @@ func UpdateLottery(c *gin.Context) {
 		Round    string `json:"round"`
 		Number   string `json:"number"`
 		Quantity int    `json:"quantity"`
+
+		// nil คือไม่แก้ไข ส่งค่าว่างมาเพื่อลบค่าเดิม
+		Note             *string   `json:"note"`
+		Tags             *[]string `json:"tags"`
+		PurchaseLocation *string   `json:"purchase_location"`
+		Vendor           *string   `json:"vendor"`
+		PurchasedAt      *string   `json:"purchased_at"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	existsFilter := bson.M{
-		"user_id": uid,
-		"round":   input.Round,
-		"number":  input.Number,
-		"_id":     bson.M{"$ne": objID},
+	// สมาชิกกลุ่มแก้สลากของกลุ่มได้ทุกใบ ไม่ใช่เฉพาะใบที่ตัวเองบันทึก
+	filter, err := lotteryScope(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
 	}
-	count, _ := getLotteryCollection().CountDocuments(context.Background(), existsFilter)
-	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Lottery number already exists in this round"})
+	filter["_id"] = objID
+	filter["deleted_at"] = nil
+
+	var lot models.Lottery
+	if err := getLotteryCollection().FindOne(context.Background(), filter).Decode(&lot); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found"})
 		return
 	}
 
-	quantity := input.Quantity
-	if quantity <= 0 {
-		quantity = 1
+	if input.Round == "" {
+		input.Round = lot.Round
+	}
+	if input.Number == "" {
+		input.Number = lot.Number
+	}
+	if input.Quantity <= 0 {
+		input.Quantity = lot.Quantity
+	}
+	if input.Quantity <= 0 {
+		input.Quantity = 1
+	}
+
+	number, msg := normalizeLotteryNumber(input.Number)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery number", "fields": gin.H{"number": msg}})
+		return
+	}
+	input.Number = number
+
+	// แก้เฉพาะโน้ต แท็ก และแหล่งที่ซื้อได้เสมอ แม้งวดจะออกรางวัลแล้ว
+	var meta lotteryMeta
+	unset := bson.M{}
+	optional := map[string]*string{
+		"note":              input.Note,
+		"purchase_location": input.PurchaseLocation,
+		"vendor":            input.Vendor,
+		"purchased_at":      input.PurchasedAt,
+	}
+	for field, value := range optional {
+		if value != nil && strings.TrimSpace(*value) == "" {
+			unset[field] = ""
+		}
+	}
+	if input.Note != nil {
+		meta.Note = *input.Note
+	}
+	if input.PurchaseLocation != nil {
+		meta.PurchaseLocation = *input.PurchaseLocation
+	}
+	if input.Vendor != nil {
+		meta.Vendor = *input.Vendor
+	}
+	if input.PurchasedAt != nil {
+		meta.PurchasedAt = *input.PurchasedAt
+	}
+	if input.Tags != nil {
+		meta.Tags = *input.Tags
+	}
+	updateData, errs := meta.fields()
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery details", "fields": errs})
+		return
+	}
+	if _, hasTags := updateData["tags"]; input.Tags != nil && !hasTags {
+		unset["tags"] = ""
+	}
+
+	ticketChanged := input.Round != lot.Round || input.Number != lot.Number || input.Quantity != lot.Quantity
+	if ticketChanged {
+		// งวดที่ออกรางวัลแล้วลบได้อย่างเดียว ถ้าอ่านงวดไม่ได้ให้ถือว่าล็อกเมื่อตรวจผลไปแล้ว
+		drawn, known := isRoundDrawn(lot.Round)
+		if drawn || (!known && lot.Status != statusUnchecked) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tickets for rounds that have already been drawn can only be deleted"})
+			return
+		}
+
+		if input.Round != lot.Round {
+			drawn, known := isRoundDrawn(input.Round)
+			if !known {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid round"})
+				return
+			}
+			if drawn {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Cannot move a ticket into a round that has already been drawn"})
+				return
+			}
+		}
+
+		existsFilter := bson.M{
+			"user_id":    uid,
+			"pool_id":    nil,
+			"round":      input.Round,
+			"number":     input.Number,
+			"_id":        bson.M{"$ne": objID},
+			"deleted_at": nil,
+		}
+		if lot.PoolID != nil {
+			delete(existsFilter, "user_id")
+			existsFilter["pool_id"] = *lot.PoolID
+		}
+		count, _ := getLotteryCollection().CountDocuments(context.Background(), existsFilter)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Lottery number already exists in this round"})
+			return
+		}
 	}
 
-	updateData := bson.M{
-		"round":      input.Round,
-		"number":     input.Number,
-		"quantity":   quantity,
-		"updated_at": time.Now(),
+	updateData["round"] = input.Round
+	updateData["number"] = input.Number
+	updateData["quantity"] = input.Quantity
+	updateData["updated_at"] = time.Now()
+
+	// เปลี่ยนเลขหรืองวดแล้วผลตรวจเดิมใช้ไม่ได้ ต้องตรวจใหม่
+	if input.Round != lot.Round || input.Number != lot.Number {
+		updateData["status"] = statusUnchecked
+		unset["claim_status"] = ""
+		unset["claimed_at"] = ""
+		unset["claimed_amount"] = ""
+	}
+
+	update := bson.M{"$set": updateData}
+	if len(unset) > 0 {
+		update["$unset"] = unset
 	}
 
 	result, err := getLotteryCollection().UpdateOne(
 		context.Background(),
-		bson.M{"_id": objID, "user_id": uid},
-		bson.M{"$set": updateData},
+		bson.M{"_id": objID, "deleted_at": nil},
+		update,
 	)
+	if mongo.IsDuplicateKeyError(err) {
+		// มีใบเลขเดียวกันถูกสร้างขึ้นหลังการตรวจด้านบน
+		c.JSON(http.StatusConflict, gin.H{"error": "Lottery number already exists in this round"})
+		return
+	}
 	if err != nil || result.MatchedCount == 0 {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot update lottery or not authorized"})
 		return
 	}
 
+	var after models.Lottery
+	if err := getLotteryCollection().FindOne(context.Background(), bson.M{"_id": objID}).Decode(&after); err == nil {
+		recordLotteryEvent(c, models.LotteryEventUpdate, &lot, &after)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Updated successfully"})
 }
 
+// DeleteLottery ย้ายสลากไปถังขยะ กู้คืนได้จนกว่าจะถูกลบถาวรตาม TRASH_RETENTION_DAYS
 func DeleteLottery(c *gin.Context) {
 	id := c.Param("id")
 	objID, _ := primitive.ObjectIDFromHex(id)
@@ -318,12 +331,33 @@ func DeleteLottery(c *gin.Context) {
 	}
 	uid, _ := primitive.ObjectIDFromHex(userID.(string))
 
-	result, err := getLotteryCollection().DeleteOne(context.Background(), bson.M{"_id": objID, "user_id": uid})
-	if err != nil || result.DeletedCount == 0 {
+	filter, err := lotteryScope(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
+	}
+	filter["_id"] = objID
+	filter["deleted_at"] = nil
+
+	now := time.Now()
+	var before models.Lottery
+	err = getLotteryCollection().FindOneAndUpdate(context.Background(),
+		filter,
+		bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}},
+	).Decode(&before)
+	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete lottery or not authorized"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
+
+	after := before
+	after.DeletedAt = &now
+	after.UpdatedAt = now
+	recordLotteryEvent(c, models.LotteryEventDelete, &before, &after)
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Moved to trash",
+		"purge_at": now.Add(config.TrashRetention),
+	})
 }
 
 func AnalyzeUserLottery(c *gin.Context) {
@@ -334,7 +368,15 @@ func AnalyzeUserLottery(c *gin.Context) {
 	}
 	uid, _ := primitive.ObjectIDFromHex(userID.(string))
 
-	cursor, err := getLotteryCollection().Find(context.Background(), bson.M{"user_id": uid})
+	// group_by จัดกลุ่มสถิติตาม tag, vendor, location, round หรือ purchase_month
+	groupBy := c.Query("group_by")
+	if groupBy != "" && !analyzeGroupDimensions[groupBy] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be one of tag, vendor, location, round, purchase_month"})
+		return
+	}
+
+	// สลากของกลุ่มนับแยกใน pools ตามสัดส่วนของผู้ใช้
+	cursor, err := getLotteryCollection().Find(context.Background(), bson.M{"user_id": uid, "pool_id": nil, "deleted_at": nil})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
 		return
@@ -347,15 +389,50 @@ func AnalyzeUserLottery(c *gin.Context) {
 		return
 	}
 
-	if len(lotteries) == 0 {
+	pools, err := activePoolsOf(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
+	}
+	poolStats := []gin.H{}
+	poolCost, poolPrize := 0.0, 0.0
+	for _, p := range pools {
+		totals, err := poolTicketTotals(p.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
+			return
+		}
+		share := p.ActiveShare(uid)
+		cost := roundBaht(float64(totals.Cost) * share)
+		prize := roundBaht(float64(totals.Prize) * share)
+		poolCost += cost
+		poolPrize += prize
+		poolStats = append(poolStats, gin.H{
+			"pool_id": p.ID,
+			"name":    p.Name,
+			"share":   roundBaht(share * 100),
+			"tickets": totals.Tickets,
+			"wins":    totals.Wins,
+			"cost":    cost,
+			"prize":   prize,
+		})
+	}
+
+	if len(lotteries) == 0 && len(pools) == 0 {
 		c.JSON(http.StatusOK, gin.H{
-			"message":       "No lottery data found",
-			"total_checked": 0,
-			"total_win":     0,
-			"win_rate":      0,
-			"total_prize":   0,
-			"lucky_number":  "NaN",
-			"results":       []models.Lottery{},
+			"message":                "No lottery data found",
+			"total_checked":          0,
+			"total_win":              0,
+			"win_rate":               0,
+			"total_prize":            0,
+			"total_cost":             0,
+			"net":                    0,
+			"total_prize_with_pools": 0,
+			"total_cost_with_pools":  0,
+			"net_with_pools":         0,
+			"lucky_number":           "NaN",
+			"results":                []models.Lottery{},
+			"pools":                  poolStats,
 		})
 		return
 	}
@@ -368,11 +445,12 @@ func AnalyzeUserLottery(c *gin.Context) {
 		Quantity int    `json:"quantity"`
 	}
 
-	var results []Result
+	results := []Result{}
 	totalWin := 0
 	totalPrize := 0
 	totalTickets := 0
 	numberCount := map[string]int{}
+	groups := map[string]*analyzeGroup{}
 
 	for _, lot := range lotteries {
 		qty := lot.Quantity
@@ -382,40 +460,21 @@ func AnalyzeUserLottery(c *gin.Context) {
 
 		totalTickets += qty
 
-		prize := 0
-		win := false
-
-		switch lot.Status {
-		case "ถูกรางวัล รางวัลที่ 1":
-			prize = 6000000
-			win = true
-		case "ถูกรางวัล รางวัลข้างเคียงรางวัลที่ 1":
-			prize = 100000
-			win = true
-		case "ถูกรางวัล รางวัลที่ 2":
-			prize = 200000
-			win = true
-		case "ถูกรางวัล รางวัลที่ 3":
-			prize = 80000
-			win = true
-		case "ถูกรางวัล รางวัลที่ 4":
-			prize = 40000
-			win = true
-		case "ถูกรางวัล รางวัลที่ 5":
-			prize = 20000
-			win = true
-		case "ถูกรางวัล รางวัลเลขหน้า 3 ตัว":
-			prize = 4000
-			win = true
-		case "ถูกรางวัล รางวัลเลขท้าย 3 ตัว":
-			prize = 4000
-			win = true
-		case "ถูกรางวัล รางวัลเลขท้าย 2 ตัว":
-			prize = 2000
-			win = true
-		default:
-			prize = 0
-			win = false
+		prize, win := prizeForStatus(lot.Status)
+
+		if groupBy != "" {
+			for _, key := range groupKeys(lot, groupBy) {
+				g, ok := groups[key]
+				if !ok {
+					g = &analyzeGroup{Key: key}
+					groups[key] = g
+				}
+				g.Tickets += qty
+				if win {
+					g.Wins += qty
+					g.Prize += prize * qty
+				}
+			}
 		}
 
 		if win {
@@ -451,12 +510,28 @@ func AnalyzeUserLottery(c *gin.Context) {
 		winRate = float64(totalWin) / float64(totalTickets) * 100
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"total_checked": totalTickets,
-		"total_win":     totalWin,
-		"win_rate":      winRate,
-		"total_prize":   totalPrize,
-		"lucky_number":  luckyNumber,
-		"results":       results,
-	})
+	// total_prize และ total_cost เป็นจำนวนเต็มของสลากส่วนตัว (แอป iOS decode เป็น Int)
+	// ยอดที่รวมส่วนแบ่งจากกลุ่มอยู่ในฟิลด์ *_with_pools
+	totalCost := totalTickets * lotteryTicketPrice
+	totalPrizeWithPools := roundBaht(float64(totalPrize) + poolPrize)
+	totalCostWithPools := roundBaht(float64(totalCost) + poolCost)
+	response := gin.H{
+		"total_checked":          totalTickets,
+		"total_win":              totalWin,
+		"win_rate":               winRate,
+		"total_prize":            totalPrize,
+		"total_cost":             totalCost,
+		"net":                    totalPrize - totalCost,
+		"total_prize_with_pools": totalPrizeWithPools,
+		"total_cost_with_pools":  totalCostWithPools,
+		"net_with_pools":         roundBaht(totalPrizeWithPools - totalCostWithPools),
+		"lucky_number":           luckyNumber,
+		"results":                results,
+		"pools":                  poolStats,
+	}
+	if groupBy != "" {
+		response["group_by"] = groupBy
+		response["groups"] = sortedAnalyzeGroups(groups)
+	}
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+func getLotteryEventCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("lottery_events")
+}
+
+// recordLotteryEvent บันทึกประวัติของสลาก c เป็น nil ได้เมื่อระบบเป็นผู้เปลี่ยน
+// การบันทึกไม่สำเร็จจะไม่ทำให้คำขอหลักล้มเหลว
+func recordLotteryEvent(c *gin.Context, eventType string, before, after *models.Lottery) {
+	var actorID, deviceID string
+	if c != nil {
+		actorID, deviceID = c.GetString("user_id"), c.GetString("device_id")
+	}
+	recordLotteryEventAs(actorID, deviceID, eventType, before, after)
+}
+
+// recordLotteryEventAs บันทึกประวัติในนามผู้ใช้ที่ระบุ ใช้กับงานเบื้องหลังที่ทำแทนผู้ใช้ เช่น แปลงรูปที่อัปโหลดตรง
+func recordLotteryEventAs(actorID, deviceID, eventType string, before, after *models.Lottery) {
+	event := models.LotteryEvent{
+		Type:      eventType,
+		Before:    before,
+		After:     after,
+		ActorID:   actorID,
+		DeviceID:  deviceID,
+		CreatedAt: time.Now(),
+	}
+	if after != nil {
+		event.LotteryID, event.UserID = after.ID, after.UserID
+	} else if before != nil {
+		event.LotteryID, event.UserID = before.ID, before.UserID
+	}
+
+	if _, err := getLotteryEventCollection().InsertOne(context.Background(), event); err != nil {
+		log.Println("record lottery event:", err)
+	}
+}
+
+// lotteryEventScope แปลง lotteryScope เป็นเงื่อนไขของ event
+// event เก็บกลุ่มไว้ใน snapshot จึงเทียบ pool_id ของ before หรือ after แทน
+func lotteryEventScope(uid primitive.ObjectID) (bson.M, error) {
+	scope, err := lotteryScope(uid)
+	if err != nil {
+		return nil, err
+	}
+	or, ok := scope["$or"].([]bson.M)
+	if !ok {
+		return scope, nil
+	}
+	conds := []bson.M{}
+	for _, cond := range or {
+		if pools, ok := cond["pool_id"]; ok {
+			conds = append(conds, bson.M{"before.pool_id": pools}, bson.M{"after.pool_id": pools})
+		} else {
+			conds = append(conds, cond)
+		}
+	}
+	return bson.M{"$or": conds}, nil
+}
+
+// ====================== History ======================
+func GetLotteryHistory(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	// ประวัติยังอยู่แม้สลากถูกลบถาวรแล้ว จึงตรวจสิทธิ์จาก event แทนตัวสลาก
+	filter, err := lotteryEventScope(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
+	}
+	filter["lottery_id"] = objID
+
+	cursor, err := getLotteryEventCollection().Find(context.Background(), filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get history"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	events := []models.LotteryEvent{}
+	if err := cursor.All(context.Background(), &events); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse history"})
+		return
+	}
+	if len(events) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found"})
+		return
+	}
+
+	// snapshot เก็บ URL ถาวรซึ่งเปิดไม่ได้แล้วเพราะไฟล์เป็น private จึงแทนด้วยลิงก์ชั่วคราวเหมือน API อื่น
+	// รูปที่ถูกลบไปแล้วจะได้ลิงก์ที่เปิดไม่เจอไฟล์
+	for i := range events {
+		if events[i].Before != nil {
+			presignLottery(c.Request.Context(), events[i].Before)
+		}
+		if events[i].After != nil {
+			presignLottery(c.Request.Context(), events[i].After)
+		}
+	}
+	c.JSON(http.StatusOK, events)
+}
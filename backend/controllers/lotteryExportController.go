@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/models"
+)
+
+type exportRow struct {
+	models.Lottery
+	Win        bool
+	Prize      int // ต่อใบ
+	PrizeTotal int
+	Pool       string  // ชื่อกลุ่ม ว่างเมื่อเป็นสลากส่วนตัว
+	Share      float64 // สัดส่วนของผู้ใช้ 0-1 สลากส่วนตัวเป็น 1
+	PrizeShare float64 // PrizeTotal ตามสัดส่วนของผู้ใช้
+}
+
+type exportRound struct {
+	Round      string
+	DrawDate   time.Time
+	Rows       []exportRow
+	Tickets    int
+	Wins       int
+	Prize      int
+	PrizeShare float64
+	Pooled     bool // มีสลากของกลุ่มในงวดนี้
+}
+
+var exportHeader = []string{
+	"round", "number", "quantity", "status", "win", "prize_per_ticket", "prize_total",
+	"tags", "vendor", "purchase_location", "purchased_at", "note", "created_at",
+	"pool", "share_percent", "prize_share",
+}
+
+// groupExportRounds จัดกลุ่มสลากตามงวดเรียงจากงวดเก่าไปใหม่ พร้อมยอดรวมของแต่ละงวด
+// สลากของกลุ่มคิดส่วนแบ่งของ uid จาก pools (กลุ่มที่ไม่อยู่ใน pools ถือว่าไม่มีส่วนแบ่ง)
+func groupExportRounds(lotteries []models.Lottery, uid primitive.ObjectID, pools map[primitive.ObjectID]models.Pool) []exportRound {
+	byRound := map[string]*exportRound{}
+	var rounds []*exportRound
+
+	for _, l := range lotteries {
+		r, ok := byRound[l.Round]
+		if !ok {
+			r = &exportRound{Round: l.Round}
+			r.DrawDate, _ = parseRoundDate(l.Round)
+			byRound[l.Round] = r
+			rounds = append(rounds, r)
+		}
+
+		qty := l.Quantity
+		if qty <= 0 {
+			qty = 1
+		}
+		prize, win := prizeForStatus(l.Status)
+		row := exportRow{Lottery: l, Win: win, Prize: prize, PrizeTotal: prize * qty, Share: 1}
+		if l.PoolID != nil {
+			pool := pools[*l.PoolID]
+			row.Pool, row.Share = pool.Name, pool.ActiveShare(uid)
+			r.Pooled = true
+		}
+		row.PrizeShare = roundBaht(float64(row.PrizeTotal) * row.Share)
+
+		r.Rows = append(r.Rows, row)
+		r.Tickets += qty
+		if win {
+			r.Wins += qty
+			r.Prize += row.PrizeTotal
+			r.PrizeShare = roundBaht(r.PrizeShare + row.PrizeShare)
+		}
+	}
+
+	sort.Slice(rounds, func(i, j int) bool {
+		if !rounds[i].DrawDate.Equal(rounds[j].DrawDate) {
+			return rounds[i].DrawDate.Before(rounds[j].DrawDate)
+		}
+		return rounds[i].Round < rounds[j].Round
+	})
+
+	result := make([]exportRound, len(rounds))
+	for i, r := range rounds {
+		sort.Slice(r.Rows, func(a, b int) bool { return r.Rows[a].Number < r.Rows[b].Number })
+		result[i] = *r
+	}
+	return result
+}
+
+// spreadsheetText กันข้อความที่ผู้ใช้พิมพ์เองถูก Excel/Sheets ตีความเป็นสูตร (CSV injection)
+// โดยเติม ' หน้าข้อความที่ขึ้นต้นด้วยอักขระที่เริ่มสูตรได้
+func spreadsheetText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// exportRecord ใช้ร่วมกันทั้ง csv และ xlsx
+func exportRecord(row exportRow) []interface{} {
+	purchasedAt := ""
+	if row.PurchasedAt != nil {
+		purchasedAt = row.PurchasedAt.In(bangkok).Format("2006-01-02")
+	}
+	return []interface{}{
+		spreadsheetText(row.Round),
+		row.Number,
+		row.Quantity,
+		row.Status,
+		strconv.FormatBool(row.Win),
+		row.Prize,
+		row.PrizeTotal,
+		spreadsheetText(strings.Join(row.Tags, ";")),
+		spreadsheetText(row.Vendor),
+		spreadsheetText(row.PurchaseLocation),
+		purchasedAt,
+		spreadsheetText(row.Note),
+		row.CreatedAt.In(bangkok).Format("2006-01-02 15:04"),
+		spreadsheetText(row.Pool),
+		roundBaht(row.Share * 100),
+		row.PrizeShare,
+	}
+}
+
+// ====================== Ticket Export ======================
+
+// ExportLotteries ส่งรายการสลากของผู้ใช้และของกลุ่มที่เป็นสมาชิกเป็น csv, xlsx หรือ pdf (สรุปรายงวด)
+// สลากของกลุ่มมีส่วนแบ่งของผู้ใช้กำกับ กรองงวดได้ด้วย ?round=
+func ExportLotteries(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or pdf"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	pools, err := activePoolsOf(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
+	}
+	poolByID := make(map[primitive.ObjectID]models.Pool, len(pools))
+	for _, p := range pools {
+		poolByID[p.ID] = p
+	}
+
+	filter, err := lotteryScope(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
+	}
+	filter["deleted_at"] = nil
+	round := c.Query("round")
+	if round != "" {
+		filter["round"] = round
+	}
+
+	cursor, err := getLotteryCollection().Find(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	lotteries := []models.Lottery{}
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse lotteries"})
+		return
+	}
+
+	rounds := groupExportRounds(lotteries, user.ID, poolByID)
+
+	filename := fmt.Sprintf("luckypus-lotteries-%s.%s", time.Now().In(bangkok).Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := writeExportCSV(c.Writer, rounds); err != nil {
+			c.Error(err)
+		}
+	case "xlsx":
+		var rows [][]interface{}
+		for _, r := range rounds {
+			for _, row := range r.Rows {
+				rows = append(rows, exportRecord(row))
+			}
+		}
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Status(http.StatusOK)
+		if err := writeXLSX(c.Writer, "Lotteries", exportHeader, rows); err != nil {
+			c.Error(err)
+		}
+	case "pdf":
+		c.Header("Content-Type", "application/pdf")
+		c.Status(http.StatusOK)
+		if _, err := buildLotteryReport(user, round, rounds).WriteTo(c.Writer); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+func writeExportCSV(w io.Writer, rounds []exportRound) error {
+	// BOM เพื่อให้ Excel อ่านภาษาไทยได้ถูกต้อง
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write(exportHeader)
+	for _, r := range rounds {
+		for _, row := range r.Rows {
+			record := exportRecord(row)
+			fields := make([]string, len(record))
+			for i, v := range record {
+				fields[i] = fmt.Sprint(v)
+			}
+			_ = cw.Write(fields)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// buildLotteryReport จัดหน้า PDF เป็นสรุปรายงวด ใช้พิมพ์หรือแนบเป็นหลักฐานทางภาษี
+func buildLotteryReport(user models.User, roundFilter string, rounds []exportRound) *pdfReport {
+	r := newPDFReport()
+
+	r.Line(16, true, pdfCell{0, "LuckyPus Lottery Report"})
+	r.Line(10, false, pdfCell{0, "Account: " + user.Username})
+	r.Line(10, false, pdfCell{0, "Generated: " + time.Now().In(bangkok).Format("2006-01-02 15:04") + " (Asia/Bangkok)"})
+	if roundFilter != "" {
+		r.Line(10, false, pdfCell{0, "Round: " + roundFilter})
+	}
+	r.Space(8)
+
+	columns := func(size float64, bold bool, number, qty, result, prize, total string) {
+		r.Line(size, bold,
+			pdfCell{0, number}, pdfCell{80, qty}, pdfCell{120, result},
+			pdfCell{300, prize}, pdfCell{400, total})
+	}
+
+	tickets, wins, prize := 0, 0, 0
+	prizeShare, pooled := 0.0, false
+	for _, round := range rounds {
+		heading := "Round " + round.Round
+		if !round.DrawDate.IsZero() {
+			heading += "  (draw date " + round.DrawDate.Format("2006-01-02") + ")"
+		}
+		r.Line(12, true, pdfCell{0, heading})
+		r.Rule()
+		columns(9, true, "Number", "Qty", "Result", "Prize/ticket", "Total (THB)")
+
+		for _, row := range round.Rows {
+			columns(9, false, row.Number, strconv.Itoa(row.Quantity), prizeLabel(row.Status),
+				formatBaht(row.Prize), formatBaht(row.PrizeTotal))
+		}
+
+		r.Rule()
+		r.Line(9, true, pdfCell{0, fmt.Sprintf("Tickets: %d    Winning tickets: %d    Total prize: %s THB",
+			round.Tickets, round.Wins, formatBaht(round.Prize))})
+		if round.Pooled {
+			r.Line(9, true, pdfCell{0, "Your share incl. pool tickets: " + formatBahtSatang(round.PrizeShare) + " THB"})
+		}
+		r.Space(12)
+
+		tickets += round.Tickets
+		wins += round.Wins
+		prize += round.Prize
+		prizeShare += round.PrizeShare
+		pooled = pooled || round.Pooled
+	}
+
+	if len(rounds) == 0 {
+		r.Line(10, false, pdfCell{0, "No tickets found."})
+		return r
+	}
+
+	r.Rule()
+	r.Line(11, true, pdfCell{0, fmt.Sprintf("Total: %d rounds, %d tickets, %d winning, %s THB",
+		len(rounds), tickets, wins, formatBaht(prize))})
+	if pooled {
+		r.Line(11, true, pdfCell{0, "Your share incl. pool tickets: " + formatBahtSatang(roundBaht(prizeShare)) + " THB"})
+	}
+	r.Line(8, false, pdfCell{0, "Prize amounts are gross, before stamp duty and withholding tax."})
+	return r
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/models"
+)
+
+func TestSpreadsheetText(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"ร้านป้าแดง":        "ร้านป้าแดง",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+66812345678":      "'+66812345678",
+		"-1+1":              "'-1+1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\t=1":              "'\t=1",
+		"a=1":               "a=1",
+	}
+	for in, want := range cases {
+		if got := spreadsheetText(in); got != want {
+			t.Errorf("spreadsheetText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteExportCSVNeutralisesFormulas(t *testing.T) {
+	row := exportRow{Lottery: models.Lottery{
+		Round:            "2025-10-16",
+		Number:           "123456",
+		Quantity:         1,
+		Note:             "=cmd|' /C calc'!A0",
+		Vendor:           "@vendor",
+		PurchaseLocation: "+ตลาด",
+		Tags:             []string{"-office", "วันเกิด"},
+		CreatedAt:        time.Now(),
+	}}
+
+	var buf bytes.Buffer
+	if err := writeExportCSV(&buf, []exportRound{{Round: row.Round, Rows: []exportRow{row}}}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(buf.Bytes(), []byte("\xEF\xBB\xBF")))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for i, name := range exportHeader {
+		got[name] = records[1][i]
+	}
+	want := map[string]string{
+		"number":            "123456",
+		"tags":              "'-office;วันเกิด",
+		"vendor":            "'@vendor",
+		"purchase_location": "'+ตลาด",
+		"note":              "'=cmd|' /C calc'!A0",
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s = %q, want %q", name, got[name], w)
+		}
+	}
+}
+
+func TestGroupExportRoundsPoolShare(t *testing.T) {
+	me, friend := primitive.NewObjectID(), primitive.NewObjectID()
+	pool := models.Pool{
+		ID:   primitive.NewObjectID(),
+		Name: "ออฟฟิศ",
+		Members: []models.PoolMember{
+			{UserID: me, Share: 1, Status: models.PoolMemberActive},
+			{UserID: friend, Share: 2, Status: models.PoolMemberActive},
+		},
+	}
+	third := "ถูกรางวัล รางวัลที่ 3"
+	rounds := groupExportRounds([]models.Lottery{
+		{UserID: me, Round: "2025-10-16", Number: "111111", Quantity: 1, Status: third},
+		{UserID: friend, PoolID: &pool.ID, Round: "2025-10-16", Number: "222222", Quantity: 2, Status: third},
+	}, me, map[primitive.ObjectID]models.Pool{pool.ID: pool})
+
+	if len(rounds) != 1 || len(rounds[0].Rows) != 2 {
+		t.Fatalf("rounds = %+v", rounds)
+	}
+	r := rounds[0]
+	personal, pooled := r.Rows[0], r.Rows[1]
+	if personal.Pool != "" || personal.Share != 1 || personal.PrizeShare != 80000 {
+		t.Errorf("personal row = %q %v %v", personal.Pool, personal.Share, personal.PrizeShare)
+	}
+	if pooled.Pool != "ออฟฟิศ" || pooled.PrizeTotal != 160000 || pooled.PrizeShare != 53333.33 {
+		t.Errorf("pool row = %q %v %v", pooled.Pool, pooled.PrizeTotal, pooled.PrizeShare)
+	}
+	if !r.Pooled || r.Prize != 240000 || r.PrizeShare != 133333.33 {
+		t.Errorf("round = pooled %v, prize %d, share %v", r.Pooled, r.Prize, r.PrizeShare)
+	}
+
+	record := exportRecord(pooled)
+	if got := record[len(record)-2]; got != 33.33 {
+		t.Errorf("share_percent = %v", got)
+	}
+	if got := formatBahtSatang(r.PrizeShare); got != "133,333.33" {
+		t.Errorf("formatBahtSatang = %q", got)
+	}
+}
@@ -0,0 +1,545 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+const lotteryImagesMax = 10
+
+var lotteryImageKinds = map[string]bool{
+	models.LotteryImageFront:   true,
+	models.LotteryImageBack:    true,
+	models.LotteryImageReceipt: true,
+}
+
+// lotteryImages คืนรูปทั้งหมดของสลาก สลากเก่าที่มีแค่ image_url จะถูกแปลงเป็นรูปหน้าหนึ่งรูป
+func lotteryImages(l models.Lottery) []models.LotteryImage {
+	if len(l.Images) > 0 || l.ImageURL == "" {
+		return l.Images
+	}
+	return []models.LotteryImage{{
+		ID:         primitive.NewObjectID(),
+		Kind:       models.LotteryImageFront,
+		Key:        extractKeyFromURL(l.ImageURL),
+		URL:        l.ImageURL,
+		UploadedAt: l.UpdatedAt,
+	}}
+}
+
+// lotteryImageKeys คืน object key ทั้งหมดที่สลากใบนี้อ้างถึง รวมรูปย่อ ใช้ตอนลบไฟล์
+func lotteryImageKeys(l models.Lottery) []string {
+	var keys []string
+	for _, img := range lotteryImages(l) {
+		keys = append(keys, imageBlobKeys(img)...)
+	}
+	return keys
+}
+
+func imageBlobKeys(img models.LotteryImage) []string {
+	var keys []string
+	if img.Key != "" {
+		keys = append(keys, img.Key)
+	}
+	for _, t := range img.Thumbnails {
+		keys = append(keys, t.Key)
+	}
+	return keys
+}
+
+// deleteImageBlobs ลบไฟล์ของรูปพร้อมรูปย่อ ใช้หลังเอารูปออกจากสลาก หรือย้อนการอัปโหลดที่บันทึกลงสลากไม่สำเร็จ
+func deleteImageBlobs(ctx context.Context, img models.LotteryImage) {
+	for _, key := range imageBlobKeys(img) {
+		if err := config.Blobs.Delete(ctx, key); err != nil {
+			log.Println("delete image:", key, err)
+		}
+	}
+}
+
+// storeLotteryImage เก็บรูปที่ผ่าน processLotteryImage แล้วพร้อมรูปย่อ และกรอกข้อมูลไฟล์ลงใน img
+// ถ้าเก็บไม่ครบจะลบไฟล์ที่เขียนไปแล้ว
+func storeLotteryImage(ctx context.Context, lotteryID primitive.ObjectID, img *models.LotteryImage, p processedImage) error {
+	base := fmt.Sprintf("lottery/%s-%s", lotteryID.Hex(), img.ID.Hex())
+	sum := sha256.Sum256(p.Data)
+
+	img.Key = base + ".jpg"
+	img.URL = config.Blobs.URL(img.Key)
+	img.ContentType = "image/jpeg"
+	img.Size = int64(len(p.Data))
+	img.Width, img.Height = p.Width, p.Height
+	img.SHA256 = hex.EncodeToString(sum[:])
+	img.Thumbnails = nil
+
+	if err := config.Blobs.Put(ctx, img.Key, p.Data, img.ContentType); err != nil {
+		return err
+	}
+	for _, t := range p.Thumbnails {
+		key := base + "-" + t.Size + ".jpg"
+		if err := config.Blobs.Put(ctx, key, t.Data, "image/jpeg"); err != nil {
+			deleteImageBlobs(ctx, *img)
+			return err
+		}
+		img.Thumbnails = append(img.Thumbnails, models.LotteryImageThumbnail{
+			Size:   t.Size,
+			Key:    key,
+			URL:    config.Blobs.URL(key),
+			Width:  t.Width,
+			Height: t.Height,
+		})
+	}
+	return nil
+}
+
+// findDuplicateImage คืนรูปเดิมที่เหมือนกับรูปที่เพิ่งประมวลผล (เทียบ sha256 ของไฟล์ที่เก็บ)
+func findDuplicateImage(images []models.LotteryImage, p processedImage) *models.LotteryImage {
+	sum := sha256.Sum256(p.Data)
+	digest := hex.EncodeToString(sum[:])
+	for i := range images {
+		if images[i].SHA256 == digest {
+			return &images[i]
+		}
+	}
+	return nil
+}
+
+// presignLottery แทน URL ของรูปทั้งหมดด้วยลิงก์ชั่วคราว เพราะไฟล์ในที่เก็บเป็น private
+// URL ถาวรยังเก็บในฐานข้อมูลตามเดิม ใช้เฉพาะตอนส่งออกทาง API
+func presignLottery(ctx context.Context, l *models.Lottery) {
+	if l.ImageURL == "" && len(l.Images) == 0 {
+		return
+	}
+
+	sign := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		u, err := config.Blobs.SignedURL(ctx, key, config.ImageURLTTL)
+		if err != nil {
+			log.Println("presign lottery image:", key, err)
+			return ""
+		}
+		return u
+	}
+
+	images := make([]models.LotteryImage, len(l.Images))
+	for i, img := range l.Images {
+		img.URL = sign(img.Key)
+		if len(img.Thumbnails) > 0 {
+			thumbs := make([]models.LotteryImageThumbnail, len(img.Thumbnails))
+			for j, t := range img.Thumbnails {
+				t.URL = sign(t.Key)
+				thumbs[j] = t
+			}
+			img.Thumbnails = thumbs
+		}
+		images[i] = img
+	}
+	l.Images = images
+
+	if len(images) > 0 {
+		l.ImageURL = images[0].URL
+	} else {
+		l.ImageURL = sign(extractKeyFromURL(l.ImageURL))
+	}
+}
+
+func presignLotteries(ctx context.Context, lotteries []models.Lottery) {
+	for i := range lotteries {
+		presignLottery(ctx, &lotteries[i])
+	}
+}
+
+// imagesUpdate คืน update ที่บันทึกรายการรูปพร้อมตั้งรูปปก (image_url) ให้ตรงกับรูปแรก
+func imagesUpdate(images []models.LotteryImage, now time.Time) bson.M {
+	if len(images) == 0 {
+		return bson.M{
+			"$unset": bson.M{"images": "", "image_url": ""},
+			"$set":   bson.M{"updated_at": now},
+		}
+	}
+	return bson.M{"$set": bson.M{
+		"images":     images,
+		"image_url":  images[0].URL,
+		"updated_at": now,
+	}}
+}
+
+// updateLotteryImages บันทึกรายการรูปใหม่ของ lot เฉพาะเมื่อสลากยังไม่ถูกแก้ตั้งแต่โหลดมา (เทียบ updated_at)
+// คืน false เมื่อชนกับการแก้ไขอื่น
+func updateLotteryImages(lot models.Lottery, images []models.LotteryImage, now time.Time) (bool, error) {
+	filter := bson.M{"_id": lot.ID, "deleted_at": nil, "updated_at": lot.UpdatedAt}
+	if lot.UpdatedAt.IsZero() {
+		filter["updated_at"] = nil // สลากเก่าที่ไม่มี updated_at
+	}
+	result, err := getLotteryCollection().UpdateOne(context.Background(), filter, imagesUpdate(images, now))
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// saveLotteryImages บันทึกรายการรูปด้วย updateLotteryImages กันรูปหายเมื่ออัปโหลด จัดลำดับ หรือลบรูปพร้อมกัน
+// ตอบ 409 ให้ client ลองใหม่และคืน false เมื่อชนกัน
+func saveLotteryImages(c *gin.Context, lot models.Lottery, images []models.LotteryImage, now time.Time) bool {
+	saved, err := updateLotteryImages(lot, images, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot update lottery"})
+		return false
+	}
+	if !saved {
+		c.JSON(http.StatusConflict, gin.H{"error": "The ticket was changed at the same time, please retry"})
+		return false
+	}
+	return true
+}
+
+func withImages(l models.Lottery, images []models.LotteryImage, now time.Time) models.Lottery {
+	l.Images = images
+	l.ImageURL = ""
+	if len(images) > 0 {
+		l.ImageURL = images[0].URL
+	}
+	l.UpdatedAt = now
+	return l
+}
+
+// findImageLottery โหลดสลากที่ผู้ใช้เข้าถึงได้ (รวมสลากของกลุ่ม) และยังไม่อยู่ในถังขยะ
+func findImageLottery(c *gin.Context, lotteryID string) (models.Lottery, bool) {
+	var lot models.Lottery
+	objID, err := primitive.ObjectIDFromHex(lotteryID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery ID"})
+		return lot, false
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	filter, err := lotteryScope(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return lot, false
+	}
+	filter["_id"] = objID
+	filter["deleted_at"] = nil
+
+	if err := getLotteryCollection().FindOne(context.Background(), filter).Decode(&lot); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found"})
+		return lot, false
+	}
+
+	// บันทึกรูปจาก image_url เดิมเป็นรายการรูป เพื่อให้ id ของรูปคงที่
+	if len(lot.Images) == 0 && lot.ImageURL != "" {
+		images := lotteryImages(lot)
+		_, err := getLotteryCollection().UpdateOne(context.Background(),
+			bson.M{"_id": lot.ID, "images": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"images": images}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot update lottery"})
+			return lot, false
+		}
+		lot.Images = images
+	}
+	return lot, true
+}
+
+// ====================== Lottery Images ======================
+
+// UploadLotteryImage เพิ่มรูปหลักฐานให้สลาก (ไม่เขียนทับรูปเดิม) kind คือ front, back หรือ receipt
+// รับเฉพาะ JPEG/PNG/HEIC ไม่เกิน 15 MB ไฟล์จะถูกแปลงเป็น JPEG ที่ไม่มี EXIF/GPS พร้อมรูปย่อ (ดู processLotteryImage)
+func UploadLotteryImage(c *gin.Context) {
+	lotteryID := c.PostForm("lottery_id")
+	if lotteryID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lottery_id is required"})
+		return
+	}
+
+	kind := c.DefaultPostForm("kind", models.LotteryImageFront)
+	if !lotteryImageKinds[kind] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be front, back or receipt"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
+		return
+	}
+
+	lot, ok := findImageLottery(c, lotteryID)
+	if !ok {
+		return
+	}
+	images := lotteryImages(lot)
+	if len(images) >= lotteryImagesMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d images per ticket", lotteryImagesMax)})
+		return
+	}
+
+	if fileHeader.Size > lotteryImageMaxSize {
+		respondImageError(c, errImageTooLarge)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot open file"})
+		return
+	}
+	defer file.Close()
+
+	fileBytes, err := io.ReadAll(io.LimitReader(file, lotteryImageMaxSize+1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot read file"})
+		return
+	}
+
+	processed, err := processLotteryImage(fileBytes)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+	if dup := findDuplicateImage(images, processed); dup != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This image is already attached to the ticket", "image": dup})
+		return
+	}
+
+	now := time.Now()
+	img := models.LotteryImage{
+		ID:         primitive.NewObjectID(),
+		Kind:       kind,
+		UploadedAt: now,
+	}
+	if err := storeLotteryImage(context.TODO(), lot.ID, &img, processed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store image", "detail": err.Error()})
+		return
+	}
+
+	images = append(images, img)
+	if !saveLotteryImages(c, lot, images, now) {
+		deleteImageBlobs(context.TODO(), img)
+		return
+	}
+
+	after := withImages(lot, images, now)
+	recordLotteryEvent(c, models.LotteryEventImageUpload, &lot, &after)
+
+	presignLottery(c.Request.Context(), &after)
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "image uploaded successfully",
+		"image_url": after.ImageURL,
+		"image":     after.Images[len(after.Images)-1],
+	})
+}
+
+func ListLotteryImages(c *gin.Context) {
+	lot, ok := findImageLottery(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	presignLottery(c.Request.Context(), &lot)
+	images := lot.Images
+	if images == nil {
+		images = []models.LotteryImage{}
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+// ReorderLotteryImages จัดลำดับรูปใหม่ image_ids ต้องมีรูปของสลากครบทุกรูป รูปแรกจะเป็นรูปปก
+func ReorderLotteryImages(c *gin.Context) {
+	var input struct {
+		ImageIDs []string `json:"image_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lot, ok := findImageLottery(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	images := lotteryImages(lot)
+
+	byID := map[string]models.LotteryImage{}
+	for _, img := range images {
+		byID[img.ID.Hex()] = img
+	}
+	if len(input.ImageIDs) != len(images) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the ticket exactly once"})
+		return
+	}
+	ordered := make([]models.LotteryImage, 0, len(images))
+	for _, id := range input.ImageIDs {
+		img, ok := byID[id]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the ticket exactly once"})
+			return
+		}
+		delete(byID, id)
+		ordered = append(ordered, img)
+	}
+
+	now := time.Now()
+	if !saveLotteryImages(c, lot, ordered, now) {
+		return
+	}
+
+	after := withImages(lot, ordered, now)
+	recordLotteryEvent(c, models.LotteryEventUpdate, &lot, &after)
+	presignLottery(c.Request.Context(), &after)
+	c.JSON(http.StatusOK, after.Images)
+}
+
+// DeleteLotteryImageByID ลบรูปเดียวออกจากสลากพร้อมไฟล์ในที่เก็บไฟล์
+func DeleteLotteryImageByID(c *gin.Context) {
+	lot, ok := findImageLottery(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	images := lotteryImages(lot)
+	remaining := make([]models.LotteryImage, 0, len(images))
+	var removed *models.LotteryImage
+	for i, img := range images {
+		if img.ID.Hex() == c.Param("image_id") {
+			removed = &images[i]
+			continue
+		}
+		remaining = append(remaining, img)
+	}
+	if removed == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	// เอารูปออกจากสลากก่อนแล้วจึงลบไฟล์ ถ้าชนกับคำขออื่นรูปจะยังอยู่ครบ
+	now := time.Now()
+	if !saveLotteryImages(c, lot, remaining, now) {
+		return
+	}
+	deleteImageBlobs(context.TODO(), *removed)
+
+	after := withImages(lot, remaining, now)
+	recordLotteryEvent(c, models.LotteryEventImageDelete, &lot, &after)
+	presignLottery(c.Request.Context(), &after)
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully", "image_url": after.ImageURL})
+}
+
+// DeleteLotteryImage ลบรูปทั้งหมดของสลาก (endpoint เดิมสำหรับ client ที่มีรูปเดียว)
+func DeleteLotteryImage(c *gin.Context) {
+	lot, ok := findImageLottery(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if !saveLotteryImages(c, lot, nil, now) {
+		return
+	}
+	for _, key := range lotteryImageKeys(lot) {
+		if err := config.Blobs.Delete(context.TODO(), key); err != nil {
+			log.Println("delete lottery image:", key, err)
+		}
+	}
+
+	after := withImages(lot, nil, now)
+	recordLotteryEvent(c, models.LotteryEventImageDelete, &lot, &after)
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+// ServeLocalBlob ส่งไฟล์จาก LocalBlobStore (ใช้ตอน STORAGE_DRIVER=local เท่านั้น)
+// เปิดได้เฉพาะลิงก์จาก SignedURL ที่ยังไม่หมดอายุ
+func ServeLocalBlob(c *gin.Context) {
+	store, ok := config.Blobs.(*config.LocalBlobStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !store.VerifySignature(key, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Link is invalid or has expired"})
+		return
+	}
+
+	streamBlob(c, key, "")
+}
+
+// streamBlob ส่งไฟล์จากที่เก็บไฟล์ผ่าน API โดยไม่เปิดเผย URL ของไฟล์
+func streamBlob(c *gin.Context, key, contentType string) {
+	body, err := config.Blobs.Get(c.Request.Context(), key)
+	if err == config.ErrBlobNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot read image"})
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot read image"})
+		return
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// GetLotteryImage ส่งรูปปกของสลาก หรือรูปที่ระบุด้วย ?image_id= ผ่าน API ที่ต้องเข้าสู่ระบบ
+// ?size=small|medium ส่งรูปย่อแทนรูปต้นฉบับ
+func GetLotteryImage(c *gin.Context) {
+	lot, ok := findImageLottery(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	images := lotteryImages(lot)
+	if len(images) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	img := images[0]
+	if id := c.Query("image_id"); id != "" {
+		found := false
+		for _, candidate := range images {
+			if candidate.ID.Hex() == id {
+				img, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+	}
+	if size := c.Query("size"); size != "" {
+		for _, t := range img.Thumbnails {
+			if t.Size == size {
+				streamBlob(c, t.Key, "image/jpeg")
+				return
+			}
+		}
+		// รูปเก่าที่อัปโหลดก่อนมีรูปย่อจะส่งรูปต้นฉบับแทน
+	}
+	streamBlob(c, img.Key, img.ContentType)
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+// useLocalBlobs ตั้ง config.Blobs เป็น LocalBlobStore ในโฟลเดอร์ชั่วคราวระหว่างเทสต์
+func useLocalBlobs(t *testing.T) *config.LocalBlobStore {
+	t.Helper()
+	store := &config.LocalBlobStore{
+		Dir:        t.TempDir(),
+		BaseURL:    "http://localhost:8080/files",
+		SigningKey: []byte("test-signing-key"),
+	}
+	prevBlobs, prevTTL := config.Blobs, config.ImageURLTTL
+	config.Blobs, config.ImageURLTTL = store, 15*time.Minute
+	t.Cleanup(func() { config.Blobs, config.ImageURLTTL = prevBlobs, prevTTL })
+	return store
+}
+
+func storeTestImage(t *testing.T, lotteryID primitive.ObjectID) models.LotteryImage {
+	t.Helper()
+	p, err := processLotteryImage(encodeTestJPEG(t, solidImage(1600, 1200, color.Gray{90})))
+	if err != nil {
+		t.Fatalf("processLotteryImage: %v", err)
+	}
+	img := models.LotteryImage{ID: primitive.NewObjectID(), Kind: "ticket"}
+	if err := storeLotteryImage(context.Background(), lotteryID, &img, p); err != nil {
+		t.Fatalf("storeLotteryImage: %v", err)
+	}
+	return img
+}
+
+func TestStoreLotteryImage(t *testing.T) {
+	store := useLocalBlobs(t)
+	ctx := context.Background()
+	img := storeTestImage(t, primitive.NewObjectID())
+
+	if img.ContentType != "image/jpeg" || img.Width != 1600 || img.Height != 1200 || img.SHA256 == "" {
+		t.Fatalf("image = %+v", img)
+	}
+	if size, err := store.Size(ctx, img.Key); err != nil || size != img.Size {
+		t.Fatalf("stored size = %d, %v; want %d", size, err, img.Size)
+	}
+	if len(img.Thumbnails) != len(lotteryThumbnailSizes) {
+		t.Fatalf("thumbnails = %d, want %d", len(img.Thumbnails), len(lotteryThumbnailSizes))
+	}
+	for _, th := range img.Thumbnails {
+		if _, err := store.Size(ctx, th.Key); err != nil {
+			t.Errorf("thumbnail %s not stored: %v", th.Size, err)
+		}
+	}
+
+	deleteImageBlobs(ctx, img)
+	for _, key := range imageBlobKeys(img) {
+		if _, err := store.Size(ctx, key); err != config.ErrBlobNotFound {
+			t.Errorf("%s still exists after deleteImageBlobs: %v", key, err)
+		}
+	}
+}
+
+func TestFindDuplicateImage(t *testing.T) {
+	useLocalBlobs(t)
+	img := storeTestImage(t, primitive.NewObjectID())
+
+	p, _ := processLotteryImage(encodeTestJPEG(t, solidImage(1600, 1200, color.Gray{90})))
+	if got := findDuplicateImage([]models.LotteryImage{img}, p); got == nil || got.ID != img.ID {
+		t.Fatalf("findDuplicateImage did not find the same photo")
+	}
+	other, _ := processLotteryImage(encodeTestJPEG(t, solidImage(10, 10, color.Gray{200})))
+	if got := findDuplicateImage([]models.LotteryImage{img}, other); got != nil {
+		t.Fatalf("findDuplicateImage matched a different photo")
+	}
+}
+
+// blobKey แยก key และ query ออกจากลิงก์ของ LocalBlobStore
+func blobKey(t *testing.T, store *config.LocalBlobStore, link string) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse %q: %v", link, err)
+	}
+	return strings.TrimPrefix(link[:strings.Index(link, "?")], store.BaseURL+"/"), u.Query()
+}
+
+func TestPresignLottery(t *testing.T) {
+	store := useLocalBlobs(t)
+	l := models.Lottery{ID: primitive.NewObjectID()}
+	img := storeTestImage(t, l.ID)
+	l.Images = []models.LotteryImage{img}
+	l.ImageURL = img.URL
+
+	presignLottery(context.Background(), &l)
+
+	if l.ImageURL != l.Images[0].URL {
+		t.Fatalf("ImageURL = %q, want the first image", l.ImageURL)
+	}
+	links := []string{l.Images[0].URL}
+	for _, th := range l.Images[0].Thumbnails {
+		links = append(links, th.URL)
+	}
+	for _, link := range links {
+		key, q := blobKey(t, store, link)
+		if !store.VerifySignature(key, q.Get("expires"), q.Get("signature")) {
+			t.Errorf("%s is not a valid signed link", link)
+		}
+	}
+	// ไม่แก้รายการรูปเดิมที่ยังอ้างถึงจากที่อื่น
+	if img.URL != store.URL(img.Key) {
+		t.Fatalf("presignLottery modified the original image")
+	}
+}
+
+func TestPresignLotteryLegacyImageURL(t *testing.T) {
+	store := useLocalBlobs(t)
+	// สลากเก่าก่อนมี images เก็บแค่ URL ถาวรของ S3
+	l := models.Lottery{ImageURL: "https://luckypus.s3.ap-southeast-1.amazonaws.com/lottery/legacy.jpg"}
+	presignLottery(context.Background(), &l)
+
+	key, q := blobKey(t, store, l.ImageURL)
+	if key != "lottery/legacy.jpg" || !store.VerifySignature(key, q.Get("expires"), q.Get("signature")) {
+		t.Fatalf("ImageURL = %q, want a signed link to lottery/legacy.jpg", l.ImageURL)
+	}
+}
+
+func newBlobRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/files/*key", ServeLocalBlob)
+	router.PUT("/files/*key", PutLocalBlob)
+	return router
+}
+
+func localPath(store *config.LocalBlobStore, link string) string {
+	return strings.TrimPrefix(link, strings.TrimSuffix(store.BaseURL, "/files"))
+}
+
+func TestServeLocalBlob(t *testing.T) {
+	store := useLocalBlobs(t)
+	router := newBlobRouter()
+	img := storeTestImage(t, primitive.NewObjectID())
+
+	link, _ := store.SignedURL(context.Background(), img.Key, time.Minute)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", localPath(store, link), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("signed GET = %d, want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want image/jpeg", ct)
+	}
+	if int64(w.Body.Len()) != img.Size {
+		t.Errorf("body = %d bytes, want %d", w.Body.Len(), img.Size)
+	}
+
+	for _, path := range []string{
+		"/files/" + img.Key,
+		strings.Replace(localPath(store, link), "signature=", "signature=0", 1),
+		strings.Replace(localPath(store, link), img.Key, img.Thumbnails[0].Key, 1),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("GET %s = %d, want 403", path, w.Code)
+		}
+	}
+}
+
+func TestPutLocalBlob(t *testing.T) {
+	store := useLocalBlobs(t)
+	router := newBlobRouter()
+	body := encodeTestPNG(t, image.NewGray(image.Rect(0, 0, 4, 4)))
+	link, headers, _ := store.SignedPutURL(context.Background(), "uploads/test", "image/png", int64(len(body)), time.Minute)
+
+	put := func(data []byte, contentType string) int {
+		req := httptest.NewRequest("PUT", localPath(store, link), bytes.NewReader(data))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := put(append(body, 0), headers["Content-Type"]); code != http.StatusForbidden {
+		t.Errorf("PUT with another size = %d, want 403", code)
+	}
+	if code := put(body, "image/jpeg"); code != http.StatusForbidden {
+		t.Errorf("PUT with another content type = %d, want 403", code)
+	}
+	if _, err := store.Size(context.Background(), "uploads/test"); err != config.ErrBlobNotFound {
+		t.Fatalf("rejected upload was stored: %v", err)
+	}
+
+	if code := put(body, headers["Content-Type"]); code != http.StatusOK {
+		t.Fatalf("signed PUT = %d, want 200", code)
+	}
+	if size, err := store.Size(context.Background(), "uploads/test"); err != nil || size != int64(len(body)) {
+		t.Fatalf("stored size = %d, %v; want %d", size, err, len(body))
+	}
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"luckyPus/models"
+)
+
+const (
+	noteMaxLength     = 500
+	tagMaxLength      = 30
+	tagsMax           = 20
+	purchaseMaxLength = 100
+)
+
+// lotteryMeta คือข้อมูลประกอบของสลากที่รับจาก client (โน้ต แท็ก แหล่งที่ซื้อ และวันที่ซื้อ)
+// purchased_at รับเป็น yyyy-mm-dd หรือ RFC3339
+type lotteryMeta struct {
+	Note             string   `json:"note"`
+	Tags             []string `json:"tags"`
+	PurchaseLocation string   `json:"purchase_location"`
+	Vendor           string   `json:"vendor"`
+	PurchasedAt      string   `json:"purchased_at"`
+}
+
+// normalizeTags ตัดช่องว่าง ตัดแท็กว่างและแท็กซ้ำ (ไม่สนตัวพิมพ์เล็กใหญ่) โดยคงลำดับเดิม
+func normalizeTags(tags []string) ([]string, string) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, t := range tags {
+		t = strings.Join(strings.Fields(t), " ")
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		if utf8.RuneCountInString(t) > tagMaxLength {
+			return nil, "each tag must be at most 30 characters"
+		}
+		seen[strings.ToLower(t)] = true
+		result = append(result, t)
+	}
+	if len(result) > tagsMax {
+		return nil, "at most 20 tags per ticket"
+	}
+	return result, ""
+}
+
+// fields ตรวจและแปลงข้อมูลเป็นฟิลด์ที่จะบันทึก ค่าว่างจะไม่ถูกใส่ใน bson.M
+func (m lotteryMeta) fields() (bson.M, map[string]string) {
+	set := bson.M{}
+	errs := map[string]string{}
+
+	if note := strings.TrimSpace(m.Note); note != "" {
+		if utf8.RuneCountInString(note) > noteMaxLength {
+			errs["note"] = "note must be at most 500 characters"
+		} else {
+			set["note"] = note
+		}
+	}
+
+	if len(m.Tags) > 0 {
+		tags, msg := normalizeTags(m.Tags)
+		if msg != "" {
+			errs["tags"] = msg
+		} else if len(tags) > 0 {
+			set["tags"] = tags
+		}
+	}
+
+	for field, value := range map[string]string{
+		"purchase_location": m.PurchaseLocation,
+		"vendor":            m.Vendor,
+	} {
+		value = strings.TrimSpace(value)
+		if utf8.RuneCountInString(value) > purchaseMaxLength {
+			errs[field] = field + " must be at most 100 characters"
+		} else if value != "" {
+			set[field] = value
+		}
+	}
+
+	if s := strings.TrimSpace(m.PurchasedAt); s != "" {
+		t, err := parseDateParam(s, false)
+		if err != nil {
+			errs["purchased_at"] = "purchased_at must be yyyy-mm-dd or RFC3339"
+		} else if t.After(time.Now().Add(24 * time.Hour)) {
+			errs["purchased_at"] = "purchased_at must not be in the future"
+		} else {
+			set["purchased_at"] = t
+		}
+	}
+
+	return set, errs
+}
+
+// joinFieldErrors รวมข้อผิดพลาดรายฟิลด์เป็นข้อความเดียว เรียงตามชื่อฟิลด์
+func joinFieldErrors(errs map[string]string) string {
+	fields := make([]string, 0, len(errs))
+	for f := range errs {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = errs[f]
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// analyzeGroupDimensions คือค่าที่ใช้กับ /lottery/analyze?group_by=
+var analyzeGroupDimensions = map[string]bool{
+	"tag":            true,
+	"vendor":         true,
+	"location":       true,
+	"round":          true,
+	"purchase_month": true,
+}
+
+type analyzeGroup struct {
+	Key     string  `json:"key"` // "" คือสลากที่ไม่ได้ระบุค่านี้
+	Tickets int     `json:"tickets"`
+	Wins    int     `json:"wins"`
+	Prize   int     `json:"prize"`
+	WinRate float64 `json:"win_rate"`
+}
+
+// groupKeys คืนกลุ่มที่สลากใบนี้อยู่ สลากที่มีหลายแท็กจะถูกนับในทุกแท็ก
+func groupKeys(l models.Lottery, by string) []string {
+	switch by {
+	case "tag":
+		if len(l.Tags) > 0 {
+			return l.Tags
+		}
+	case "vendor":
+		return []string{l.Vendor}
+	case "location":
+		return []string{l.PurchaseLocation}
+	case "round":
+		return []string{l.Round}
+	case "purchase_month":
+		if l.PurchasedAt != nil {
+			return []string{l.PurchasedAt.In(bangkok).Format("2006-01")}
+		}
+	}
+	return []string{""}
+}
+
+func sortedAnalyzeGroups(groups map[string]*analyzeGroup) []analyzeGroup {
+	result := make([]analyzeGroup, 0, len(groups))
+	for _, g := range groups {
+		if g.Tickets > 0 {
+			g.WinRate = float64(g.Wins) / float64(g.Tickets) * 100
+		}
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Tickets != result[j].Tickets {
+			return result[i].Tickets > result[j].Tickets
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
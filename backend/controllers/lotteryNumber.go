@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"strings"
+	"unicode"
+)
+
+const lotteryNumberLength = 6
+
+// normalizeLotteryDigits แปลงเลขไทย ๐-๙ เป็นเลขอารบิก และตัดช่องว่างกับขีดที่ผู้ใช้มักพิมพ์คั่นไว้
+func normalizeLotteryDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '๐' && r <= '๙':
+			b.WriteRune('0' + (r - '๐'))
+		case unicode.IsSpace(r), r == '-', r == '–', r == '—':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeLotteryNumber คืนเลขสลากรูปแบบมาตรฐาน (ตัวเลข ASCII 6 หลัก)
+// หรือข้อความผิดพลาดเมื่อแปลงไม่ได้
+func normalizeLotteryNumber(s string) (string, string) {
+	n := normalizeLotteryDigits(s)
+	if n == "" {
+		return "", "number is required"
+	}
+	for _, r := range n {
+		if r < '0' || r > '9' {
+			return "", "number must contain only digits"
+		}
+	}
+	if len(n) != lotteryNumberLength {
+		return "", "number must be exactly 6 digits"
+	}
+	return n, ""
+}
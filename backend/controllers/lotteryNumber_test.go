@@ -0,0 +1,31 @@
+package controllers
+
+import "testing"
+
+func TestNormalizeLotteryNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{"123456", "123456", ""},
+		{"000001", "000001", ""},
+		{"๑๒๓๔๕๖", "123456", ""},
+		{"12 34 56", "123456", ""},
+		{"123-456", "123456", ""},
+		{"123–456", "123456", ""},
+		{" ๐๐๙ ๘๗๖ ", "009876", ""},
+		{"", "", "number is required"},
+		{" - ", "", "number is required"},
+		{"12345", "", "number must be exactly 6 digits"},
+		{"1234567", "", "number must be exactly 6 digits"},
+		{"12a456", "", "number must contain only digits"},
+		{"１２３４５６", "", "number must contain only digits"},
+	}
+	for _, tt := range tests {
+		got, msg := normalizeLotteryNumber(tt.in)
+		if got != tt.want || msg != tt.wantErr {
+			t.Errorf("normalizeLotteryNumber(%q) = %q, %q; want %q, %q", tt.in, got, msg, tt.want, tt.wantErr)
+		}
+	}
+}
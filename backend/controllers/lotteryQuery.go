@@ -0,0 +1,375 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/models"
+)
+
+const (
+	lotteryPageDefault = 50
+	lotteryPageMax     = 200
+)
+
+// lotterySortFields คือฟิลด์ที่อนุญาตให้เรียงลำดับได้ ทุกฟิลด์มี index รองรับใน EnsureLotteryIndexes
+var lotterySortFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"number":     true,
+	"quantity":   true,
+}
+
+var digitsOnly = regexp.MustCompile(`^[0-9]{1,6}$`)
+
+type lotteryQuery struct {
+	Filter    bson.M
+	SortField string
+	SortDir   int
+	Limit     int64
+	After     *lotteryCursor
+}
+
+// lotteryCursor คือตำแหน่งของรายการสุดท้ายในหน้าก่อนหน้า ส่งให้ client แบบ base64 ที่อ่านไม่ออก
+type lotteryCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+}
+
+func encodeLotteryCursor(sortField string, l models.Lottery) string {
+	var value interface{}
+	switch sortField {
+	case "created_at":
+		value = l.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "updated_at":
+		// สลากเก่าอาจไม่มี updated_at (omitempty) ใช้ null แทนเพื่อให้เลื่อนหน้าผ่านกลุ่มนี้ได้
+		if !l.UpdatedAt.IsZero() {
+			value = l.UpdatedAt.UTC().Format(time.RFC3339Nano)
+		}
+	case "number":
+		value = l.Number
+	case "quantity":
+		value = l.Quantity
+	}
+	raw, _ := json.Marshal(value)
+	b, _ := json.Marshal(lotteryCursor{Sort: sortField, Value: raw, ID: l.ID.Hex()})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeLotteryCursor(s string) (*lotteryCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cur lotteryCursor
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+// cursorValue แปลงค่าใน cursor กลับเป็นชนิดเดียวกับที่เก็บใน MongoDB
+// คืน nil เมื่อรายการสุดท้ายไม่มีฟิลด์ที่ใช้เรียง
+func (cur *lotteryCursor) cursorValue() (interface{}, error) {
+	if cur.Sort == "updated_at" && string(cur.Value) == "null" {
+		return nil, nil
+	}
+	switch cur.Sort {
+	case "created_at", "updated_at":
+		var s string
+		if err := json.Unmarshal(cur.Value, &s); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case "number":
+		var s string
+		err := json.Unmarshal(cur.Value, &s)
+		return s, err
+	case "quantity":
+		var n int
+		err := json.Unmarshal(cur.Value, &n)
+		return n, err
+	}
+	return nil, errors.New("unknown sort field")
+}
+
+// parseDateParam รับทั้ง RFC3339 และ yyyy-mm-dd (ตามเวลาไทย)
+// endOfDay ใช้กับขอบบนของช่วง เพื่อให้ to=2025-10-16 รวมทั้งวัน
+func parseDateParam(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, bangkok)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// parseLotteryQuery อ่าน query string ของ GET /lottery/
+// ถ้าไม่ส่งทั้ง limit และ cursor จะคืนทุกรายการเหมือนเดิม (client รุ่นเก่าไม่รู้จักการแบ่งหน้า)
+// limit, cursor, sort (เช่น -created_at), round, status, prefix, suffix, has_image, from, to
+// tag, vendor, location, note, purchased_from, purchased_to
+func parseLotteryQuery(c *gin.Context, uid primitive.ObjectID) (lotteryQuery, map[string]string) {
+	q := lotteryQuery{
+		Filter:    bson.M{"user_id": uid, "pool_id": nil, "deleted_at": nil},
+		SortField: "created_at",
+		SortDir:   -1,
+	}
+	errs := map[string]string{}
+	if c.Query("cursor") != "" {
+		q.Limit = lotteryPageDefault
+	}
+
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 1 || n > lotteryPageMax {
+			errs["limit"] = "limit must be between 1 and 200"
+		} else {
+			q.Limit = n
+		}
+	}
+
+	if s := c.Query("sort"); s != "" {
+		field, dir := s, 1
+		if strings.HasPrefix(s, "-") {
+			field, dir = s[1:], -1
+		}
+		if !lotterySortFields[field] {
+			errs["sort"] = "sort must be one of created_at, updated_at, number, quantity (prefix - for descending)"
+		} else {
+			q.SortField, q.SortDir = field, dir
+		}
+	}
+
+	if s := c.Query("cursor"); s != "" {
+		cur, err := decodeLotteryCursor(s)
+		if err != nil || cur.Sort != q.SortField {
+			errs["cursor"] = "invalid cursor"
+		} else {
+			q.After = cur
+		}
+	}
+
+	if round := c.Query("round"); round != "" {
+		q.Filter["round"] = round
+	}
+
+	if status := c.Query("status"); status != "" {
+		statuses := strings.Split(status, ",")
+		if len(statuses) == 1 {
+			q.Filter["status"] = status
+		} else {
+			q.Filter["status"] = bson.M{"$in": statuses}
+		}
+	}
+
+	prefix, suffix := normalizeLotteryDigits(c.Query("prefix")), normalizeLotteryDigits(c.Query("suffix"))
+	if prefix != "" && !digitsOnly.MatchString(prefix) {
+		errs["prefix"] = "prefix must be 1-6 digits"
+	}
+	if suffix != "" && !digitsOnly.MatchString(suffix) {
+		errs["suffix"] = "suffix must be 1-6 digits"
+	}
+	// prefix ที่ขึ้นต้นด้วย ^ ใช้ index ของ number ได้ จึงแยกจาก suffix
+	// (regex เดียวแบบ ^prefix.*suffix$ ไม่ตรงกับเลขที่ prefix และ suffix ซ้อนกัน เช่น 123 กับ 12/23)
+	switch {
+	case prefix != "" && suffix != "":
+		q.Filter["$and"] = []bson.M{
+			{"number": primitive.Regex{Pattern: "^" + prefix}},
+			{"number": primitive.Regex{Pattern: suffix + "$"}},
+		}
+	case prefix != "":
+		q.Filter["number"] = primitive.Regex{Pattern: "^" + prefix}
+	case suffix != "":
+		q.Filter["number"] = primitive.Regex{Pattern: suffix + "$"}
+	}
+
+	switch c.Query("has_image") {
+	case "":
+	case "true":
+		q.Filter["image_url"] = bson.M{"$exists": true, "$ne": ""}
+	case "false":
+		q.Filter["$or"] = []bson.M{
+			{"image_url": bson.M{"$exists": false}},
+			{"image_url": ""},
+		}
+	default:
+		errs["has_image"] = "has_image must be true or false"
+	}
+
+	if tag := c.Query("tag"); tag != "" {
+		tags, _ := normalizeTags(strings.Split(tag, ","))
+		if len(tags) == 1 {
+			q.Filter["tags"] = tags[0]
+		} else if len(tags) > 1 {
+			q.Filter["tags"] = bson.M{"$all": tags}
+		}
+	}
+	if vendor := strings.TrimSpace(c.Query("vendor")); vendor != "" {
+		q.Filter["vendor"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(vendor) + "$", Options: "i"}
+	}
+	if location := strings.TrimSpace(c.Query("location")); location != "" {
+		q.Filter["purchase_location"] = primitive.Regex{Pattern: regexp.QuoteMeta(location), Options: "i"}
+	}
+	if note := strings.TrimSpace(c.Query("note")); note != "" {
+		q.Filter["note"] = primitive.Regex{Pattern: regexp.QuoteMeta(note), Options: "i"}
+	}
+
+	purchasedAt := bson.M{}
+	if s := c.Query("purchased_from"); s != "" {
+		if t, err := parseDateParam(s, false); err != nil {
+			errs["purchased_from"] = "purchased_from must be yyyy-mm-dd or RFC3339"
+		} else {
+			purchasedAt["$gte"] = t
+		}
+	}
+	if s := c.Query("purchased_to"); s != "" {
+		if t, err := parseDateParam(s, true); err != nil {
+			errs["purchased_to"] = "purchased_to must be yyyy-mm-dd or RFC3339"
+		} else {
+			purchasedAt["$lte"] = t
+		}
+	}
+	if len(purchasedAt) > 0 {
+		q.Filter["purchased_at"] = purchasedAt
+	}
+
+	createdAt := bson.M{}
+	if s := c.Query("from"); s != "" {
+		if t, err := parseDateParam(s, false); err != nil {
+			errs["from"] = "from must be yyyy-mm-dd or RFC3339"
+		} else {
+			createdAt["$gte"] = t
+		}
+	}
+	if s := c.Query("to"); s != "" {
+		if t, err := parseDateParam(s, true); err != nil {
+			errs["to"] = "to must be yyyy-mm-dd or RFC3339"
+		} else {
+			createdAt["$lte"] = t
+		}
+	}
+	if len(createdAt) > 0 {
+		q.Filter["created_at"] = createdAt
+	}
+
+	return q, errs
+}
+
+// findOptions คืน filter และ options สำหรับดึงหน้าถัดไป โดยดึงเกิน 1 รายการเพื่อรู้ว่ายังมีหน้าต่อไปหรือไม่
+func (q lotteryQuery) findOptions() (bson.M, *options.FindOptions, error) {
+	filter := q.Filter
+	if q.After != nil {
+		value, err := q.After.cursorValue()
+		if err != nil {
+			return nil, nil, err
+		}
+		lastID, err := primitive.ObjectIDFromHex(q.After.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		op := "$gt"
+		if q.SortDir < 0 {
+			op = "$lt"
+		}
+		// MongoDB เรียงรายการที่ไม่มีฟิลด์ (null) ไว้ก่อนทุกค่า แต่ $gt/$lt เทียบกับ null ไม่ได้
+		// จึงต้องเพิ่มเงื่อนไขของกลุ่ม null เอง
+		after := []bson.M{{q.SortField: value, "_id": bson.M{op: lastID}}}
+		switch {
+		case value == nil && q.SortDir > 0:
+			after = append(after, bson.M{q.SortField: bson.M{"$ne": nil}})
+		case value != nil:
+			after = append(after, bson.M{q.SortField: bson.M{op: value}})
+			if q.SortDir < 0 {
+				after = append(after, bson.M{q.SortField: nil})
+			}
+		}
+		filter = bson.M{"$and": []bson.M{q.Filter, {"$or": after}}}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: q.SortField, Value: q.SortDir}, {Key: "_id", Value: q.SortDir}})
+	if q.Limit > 0 {
+		opts.SetLimit(q.Limit + 1)
+	}
+	return filter, opts, nil
+}
+
+// EnsureLotteryIndexes สร้าง compound index ที่ใช้กับการแบ่งหน้าและตัวกรองของ GET /lottery/
+// และ index ของประวัติสลาก
+func EnsureLotteryIndexes() {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "number", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "quantity", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "round", Value: 1}, {Key: "number", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "pool_id", Value: 1}, {Key: "round", Value: 1}, {Key: "number", Value: 1}}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := getLotteryCollection().Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Println("create lottery indexes:", err)
+	}
+
+	// สลากใบเดิมที่งวดและเลขเดียวกันมีได้ใบเดียว (สลากในถังขยะมี deleted_at ต่างกันจึงไม่ชน)
+	// กันคำขอพร้อมกันที่ผ่านการตรวจใบซ้ำทั้งคู่ สลากของกลุ่มเทียบทั้งกลุ่มไม่ว่าใครบันทึก
+	_, err := getLotteryCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1}, {Key: "pool_id", Value: 1},
+				{Key: "round", Value: 1}, {Key: "number", Value: 1}, {Key: "deleted_at", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("unique_ticket"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "pool_id", Value: 1},
+				{Key: "round", Value: 1}, {Key: "number", Value: 1}, {Key: "deleted_at", Value: 1},
+			},
+			Options: options.Index().
+				SetUnique(true).
+				SetName("unique_pool_ticket").
+				SetPartialFilterExpression(bson.M{"pool_id": bson.M{"$type": "objectId"}}),
+		},
+	})
+	if err != nil {
+		log.Println("create unique lottery indexes (merge duplicates with /admin/lotteries/invalid-numbers/fix first):", err)
+	}
+
+	_, err = getLotteryEventCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "lottery_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		log.Println("create lottery event indexes:", err)
+	}
+
+	_, err = getImageUploadCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "lottery_id", Value: 1}, {Key: "expires_at", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}},
+	})
+	if err != nil {
+		log.Println("create image upload indexes:", err)
+	}
+}
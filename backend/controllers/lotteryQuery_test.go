@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/models"
+)
+
+func TestLotteryCursorRoundTrip(t *testing.T) {
+	created := time.Date(2025, 10, 16, 8, 30, 0, 123456789, time.UTC)
+	l := models.Lottery{
+		ID:        primitive.NewObjectID(),
+		Number:    "012345",
+		Quantity:  3,
+		CreatedAt: created,
+		UpdatedAt: created.Add(time.Hour),
+	}
+
+	tests := []struct {
+		sort string
+		want interface{}
+	}{
+		{"created_at", created},
+		{"updated_at", created.Add(time.Hour)},
+		{"number", "012345"},
+		{"quantity", 3},
+	}
+	for _, tt := range tests {
+		cur, err := decodeLotteryCursor(encodeLotteryCursor(tt.sort, l))
+		if err != nil {
+			t.Fatalf("%s: decodeLotteryCursor: %v", tt.sort, err)
+		}
+		if cur.Sort != tt.sort || cur.ID != l.ID.Hex() {
+			t.Fatalf("%s: cursor = %+v", tt.sort, cur)
+		}
+		got, err := cur.cursorValue()
+		if err != nil {
+			t.Fatalf("%s: cursorValue: %v", tt.sort, err)
+		}
+		if want, ok := tt.want.(time.Time); ok {
+			if gotTime, _ := got.(time.Time); !gotTime.Equal(want) {
+				t.Errorf("%s: cursorValue = %v, want %v", tt.sort, got, want)
+			}
+		} else if got != tt.want {
+			t.Errorf("%s: cursorValue = %#v, want %#v", tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestLotteryCursorMissingUpdatedAt(t *testing.T) {
+	l := models.Lottery{ID: primitive.NewObjectID()}
+	cur, err := decodeLotteryCursor(encodeLotteryCursor("updated_at", l))
+	if err != nil {
+		t.Fatalf("decodeLotteryCursor: %v", err)
+	}
+	value, err := cur.cursorValue()
+	if err != nil || value != nil {
+		t.Fatalf("cursorValue = %v, %v; want nil, nil", value, err)
+	}
+}
+
+func TestDecodeLotteryCursorInvalid(t *testing.T) {
+	for _, s := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := decodeLotteryCursor(s); err == nil {
+			t.Errorf("decodeLotteryCursor(%q) succeeded", s)
+		}
+	}
+}
+
+// afterBranches คืนเงื่อนไขใน $or ที่ findOptions เพิ่มเพื่อเลื่อนไปหน้าถัดไป
+func afterBranches(t *testing.T, filter bson.M) []bson.M {
+	t.Helper()
+	and, ok := filter["$and"].([]bson.M)
+	if !ok || len(and) != 2 {
+		t.Fatalf("filter = %v, want $and of the base filter and the cursor", filter)
+	}
+	return and[1]["$or"].([]bson.M)
+}
+
+func TestFindOptionsCursor(t *testing.T) {
+	lastID := primitive.NewObjectID()
+	updated := time.Date(2025, 10, 16, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		l     models.Lottery
+		dir   int
+		want  []bson.M
+		limit int64
+	}{
+		{
+			"descending with a value also includes documents without the field",
+			models.Lottery{ID: lastID, UpdatedAt: updated},
+			-1,
+			[]bson.M{
+				{"updated_at": updated, "_id": bson.M{"$lt": lastID}},
+				{"updated_at": bson.M{"$lt": updated}},
+				{"updated_at": nil},
+			},
+			50,
+		},
+		{
+			"ascending with a value",
+			models.Lottery{ID: lastID, UpdatedAt: updated},
+			1,
+			[]bson.M{
+				{"updated_at": updated, "_id": bson.M{"$gt": lastID}},
+				{"updated_at": bson.M{"$gt": updated}},
+			},
+			50,
+		},
+		{
+			"ascending from a document without the field",
+			models.Lottery{ID: lastID},
+			1,
+			[]bson.M{
+				{"updated_at": nil, "_id": bson.M{"$gt": lastID}},
+				{"updated_at": bson.M{"$ne": nil}},
+			},
+			50,
+		},
+		{
+			"descending from a document without the field",
+			models.Lottery{ID: lastID},
+			-1,
+			[]bson.M{
+				{"updated_at": nil, "_id": bson.M{"$lt": lastID}},
+			},
+			50,
+		},
+	}
+	for _, tt := range tests {
+		cur, _ := decodeLotteryCursor(encodeLotteryCursor("updated_at", tt.l))
+		q := lotteryQuery{Filter: bson.M{"deleted_at": nil}, SortField: "updated_at", SortDir: tt.dir, Limit: tt.limit, After: cur}
+		filter, opts, err := q.findOptions()
+		if err != nil {
+			t.Fatalf("%s: findOptions: %v", tt.name, err)
+		}
+		if got := afterBranches(t, filter); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: cursor filter = %v, want %v", tt.name, got, tt.want)
+		}
+		if opts.Limit == nil || *opts.Limit != tt.limit+1 {
+			t.Errorf("%s: limit = %v, want %d", tt.name, opts.Limit, tt.limit+1)
+		}
+	}
+}
+
+func newQueryContext(rawQuery string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/lottery/?"+rawQuery, nil)
+	return c
+}
+
+func TestParseLotteryQueryPaging(t *testing.T) {
+	uid := primitive.NewObjectID()
+	cursor := encodeLotteryCursor("created_at", models.Lottery{ID: primitive.NewObjectID(), CreatedAt: time.Now()})
+
+	tests := []struct {
+		query string
+		limit int64
+	}{
+		{"", 0}, // client เดิมที่ไม่รู้จักการแบ่งหน้าได้ทุกรายการ
+		{"limit=10", 10},
+		{"cursor=" + cursor, lotteryPageDefault},
+		{"limit=5&cursor=" + cursor, 5},
+	}
+	for _, tt := range tests {
+		q, errs := parseLotteryQuery(newQueryContext(tt.query), uid)
+		if len(errs) > 0 {
+			t.Fatalf("parseLotteryQuery(%q) errors: %v", tt.query, errs)
+		}
+		if q.Limit != tt.limit {
+			t.Errorf("parseLotteryQuery(%q).Limit = %d, want %d", tt.query, q.Limit, tt.limit)
+		}
+		_, opts, err := q.findOptions()
+		if err != nil {
+			t.Fatalf("findOptions(%q): %v", tt.query, err)
+		}
+		if (opts.Limit != nil) != (tt.limit > 0) {
+			t.Errorf("findOptions(%q) limit = %v", tt.query, opts.Limit)
+		}
+	}
+}
+
+func TestParseLotteryQueryFilter(t *testing.T) {
+	uid := primitive.NewObjectID()
+	q, errs := parseLotteryQuery(newQueryContext("round=2025-10-16&status=a,b&prefix=๑๒&suffix=9"), uid)
+	if len(errs) > 0 {
+		t.Fatalf("errors: %v", errs)
+	}
+	want := bson.M{
+		"user_id":    uid,
+		"pool_id":    nil, // สลากของกลุ่มดูผ่าน ?pool_id= เท่านั้น
+		"deleted_at": nil,
+		"round":      "2025-10-16",
+		"status":     bson.M{"$in": []string{"a", "b"}},
+		"$and": []bson.M{
+			{"number": primitive.Regex{Pattern: "^12"}},
+			{"number": primitive.Regex{Pattern: "9$"}},
+		},
+	}
+	if !reflect.DeepEqual(q.Filter, want) {
+		t.Fatalf("Filter = %v, want %v", q.Filter, want)
+	}
+
+	q, _ = parseLotteryQuery(newQueryContext("prefix=12"), uid)
+	if got := q.Filter["number"]; got != (primitive.Regex{Pattern: "^12"}) {
+		t.Fatalf("prefix only: number = %v", got)
+	}
+	q, _ = parseLotteryQuery(newQueryContext("suffix=23"), uid)
+	if got := q.Filter["number"]; got != (primitive.Regex{Pattern: "23$"}) {
+		t.Fatalf("suffix only: number = %v", got)
+	}
+}
+
+func TestParseLotteryQueryInvalid(t *testing.T) {
+	_, errs := parseLotteryQuery(newQueryContext("limit=0&sort=-price&cursor=xyz&has_image=maybe&prefix=abc&from=yesterday"), primitive.NewObjectID())
+	for _, field := range []string{"limit", "sort", "cursor", "has_image", "prefix", "from"} {
+		if errs[field] == "" {
+			t.Errorf("missing error for %s (got %v)", field, errs)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+
+	"luckyPus/middleware"
+	"luckyPus/models"
+)
+
+const (
+	mfaChallengeTTL    = 5 * time.Minute
+	recoveryCodeCount  = 10
+	mfaChallengeTokTyp = "mfa"
+)
+
+// signMFAChallenge ออกโทเค็นอายุสั้นสำหรับขั้นตอนที่สองของการเข้าสู่ระบบ
+// โทเค็นนี้มี typ = "mfa" และ AuthMiddleware จะไม่ยอมรับเป็น access token
+func signMFAChallenge(userID primitive.ObjectID, deviceID, name, anonHash string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       userID.Hex(),
+		"typ":       mfaChallengeTokTyp,
+		"device_id": deviceID,
+		"name":      name,
+		"anon_hash": anonHash,
+		"exp":       time.Now().Add(mfaChallengeTTL).Unix(),
+	})
+	return token.SignedString(jwtKey)
+}
+
+func parseMFAChallenge(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["typ"] != mfaChallengeTokTyp {
+		return nil, errors.New("invalid mfa token")
+	}
+	return claims, nil
+}
+
+// checkSecondFactor ตรวจรหัส TOTP หรือ recovery code ของผู้ใช้
+// recovery code ที่ใช้แล้วจะถูกลบออก และ TOTP step ที่ใช้แล้วจะใช้ซ้ำไม่ได้
+func checkSecondFactor(user models.User, code, recoveryCode string) bool {
+	if code != "" && user.TOTPSecret != "" {
+		step, ok := verifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return false
+		}
+		res, err := getUserCollection().UpdateOne(context.Background(),
+			bson.M{"_id": user.ID, "totp_last_step": bson.M{"$not": bson.M{"$gte": step}}},
+			bson.M{"$set": bson.M{"totp_last_step": step}},
+		)
+		return err == nil && res.ModifiedCount == 1
+	}
+
+	if recoveryCode != "" {
+		hash := sha256Hex(normalizeRecoveryCode(recoveryCode))
+		res, err := getUserCollection().UpdateOne(context.Background(),
+			bson.M{"_id": user.ID, "recovery_codes": hash},
+			bson.M{"$pull": bson.M{"recovery_codes": hash}},
+		)
+		return err == nil && res.ModifiedCount == 1
+	}
+
+	return false
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, sha256Hex(normalizeRecoveryCode(code)))
+	}
+	return hashes
+}
+
+func findCurrentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return user, false
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": uid}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ไม่พบบัญชีผู้ใช้"})
+		return user, false
+	}
+	return user, true
+}
+
+// ====================== Login Step 2 ======================
+func LoginMFA(c *gin.Context) {
+	var req struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	claims, err := parseMFAChallenge(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "โทเค็นไม่ถูกต้องหรือหมดอายุ"})
+		return
+	}
+
+	sub, _ := claims["sub"].(string)
+	uid, _ := primitive.ObjectIDFromHex(sub)
+
+	var user models.User
+	if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": uid}).Decode(&user); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "โทเค็นไม่ถูกต้องหรือหมดอายุ"})
+		return
+	}
+
+	if !middleware.CheckLoginLockout(c, user.Username) {
+		return
+	}
+	if !user.MFAEnabled || !checkSecondFactor(user, req.Code, req.RecoveryCode) {
+		middleware.RecordLoginFailure(c, user.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "รหัสยืนยันไม่ถูกต้อง"})
+		return
+	}
+	middleware.ResetLoginFailures(c, user.Username)
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": accountDisabledMessage})
+		return
+	}
+
+	deviceID, _ := claims["device_id"].(string)
+	name, _ := claims["name"].(string)
+	anonHash, _ := claims["anon_hash"].(string)
+	respondWithLoginTokens(c, user, deviceID, name, anonHash)
+}
+
+// ====================== MFA Management ======================
+func EnrollMFA(c *gin.Context) {
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "บัญชีนี้ไม่ได้ใช้รหัสผ่าน จึงไม่สามารถเปิดยืนยันตัวตนสองชั้นได้"})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "เปิดใช้งานยืนยันตัวตนสองชั้นอยู่แล้ว"})
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้างรหัสลับได้"})
+		return
+	}
+
+	_, err = getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"totp_pending_secret": secret}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้างรหัสลับได้"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totpURI(secret, user.Username),
+		"message":     "สแกน QR code ด้วยแอป Authenticator แล้วยืนยันด้วยรหัส 6 หลัก",
+	})
+}
+
+func EnableMFA(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPPendingSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "กรุณาเริ่มการตั้งค่ายืนยันตัวตนสองชั้นก่อน"})
+		return
+	}
+
+	step, valid := verifyTOTP(user.TOTPPendingSecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "รหัสยืนยันไม่ถูกต้อง"})
+		return
+	}
+
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้าง recovery code ได้"})
+		return
+	}
+
+	_, err = getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{
+			"$set": bson.M{
+				"mfa_enabled":    true,
+				"totp_secret":    user.TOTPPendingSecret,
+				"totp_last_step": step,
+				"recovery_codes": hashRecoveryCodes(codes),
+			},
+			"$unset": bson.M{"totp_pending_secret": ""},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเปิดใช้งานยืนยันตัวตนสองชั้นได้"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+		"message":        "เปิดใช้งานยืนยันตัวตนสองชั้นสำเร็จ กรุณาเก็บ recovery code ไว้ในที่ปลอดภัย",
+	})
+}
+
+func DisableMFA(c *gin.Context) {
+	var req struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ยังไม่ได้เปิดใช้งานยืนยันตัวตนสองชั้น"})
+		return
+	}
+
+	// นับความล้มเหลวร่วมกับการเข้าสู่ระบบ กันการเดารหัส TOTP ด้วย access token ที่หลุด
+	if !middleware.CheckLoginLockout(c, user.Username) {
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil ||
+		!checkSecondFactor(user, req.Code, req.RecoveryCode) {
+		middleware.RecordLoginFailure(c, user.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "รหัสผ่านหรือรหัสยืนยันไม่ถูกต้อง"})
+		return
+	}
+	middleware.ResetLoginFailures(c, user.Username)
+
+	_, err := getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{
+			"$set": bson.M{"mfa_enabled": false},
+			"$unset": bson.M{
+				"totp_secret":         "",
+				"totp_pending_secret": "",
+				"totp_last_step":      "",
+				"recovery_codes":      "",
+			},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถปิดการยืนยันตัวตนสองชั้นได้"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ปิดการยืนยันตัวตนสองชั้นสำเร็จ"})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if !middleware.CheckLoginLockout(c, user.Username) {
+		return
+	}
+	if !user.MFAEnabled || !checkSecondFactor(user, req.Code, "") {
+		middleware.RecordLoginFailure(c, user.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "รหัสยืนยันไม่ถูกต้อง"})
+		return
+	}
+	middleware.ResetLoginFailures(c, user.Username)
+
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้าง recovery code ได้"})
+		return
+	}
+
+	_, err = getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"recovery_codes": hashRecoveryCodes(codes)}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถสร้าง recovery code ได้"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
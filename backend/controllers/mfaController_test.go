@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/models"
+)
+
+// useJWTKey ตั้ง jwtKey ระหว่างเทสต์แทน Init ที่ต้องต่อฐานข้อมูล
+func useJWTKey(t *testing.T) {
+	t.Helper()
+	prev := jwtKey
+	jwtKey = []byte("test-jwt-secret")
+	t.Cleanup(func() { jwtKey = prev })
+}
+
+func TestMFAChallengeRoundTrip(t *testing.T) {
+	useJWTKey(t)
+	uid := primitive.NewObjectID()
+
+	token, err := signMFAChallenge(uid, "device-1", "iPhone", "anon")
+	if err != nil {
+		t.Fatalf("signMFAChallenge: %v", err)
+	}
+	claims, err := parseMFAChallenge(token)
+	if err != nil {
+		t.Fatalf("parseMFAChallenge: %v", err)
+	}
+	for key, want := range map[string]string{"sub": uid.Hex(), "device_id": "device-1", "name": "iPhone", "anon_hash": "anon"} {
+		if claims[key] != want {
+			t.Errorf("claims[%q] = %v, want %q", key, claims[key], want)
+		}
+	}
+}
+
+func TestMFAChallengeRejects(t *testing.T) {
+	useJWTKey(t)
+	uid := primitive.NewObjectID()
+	valid, _ := signMFAChallenge(uid, "", "", "")
+
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": uid.Hex(),
+		"typ": mfaChallengeTokTyp,
+		"exp": time.Now().Add(-time.Minute).Unix(),
+	}).SignedString(jwtKey)
+
+	// access token ใช้แทนโทเค็นขั้นที่สองไม่ได้
+	access, _ := signAccessToken(models.User{ID: uid}, "")
+
+	otherKey, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": uid.Hex(),
+		"typ": mfaChallengeTokTyp,
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("another-secret"))
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub": uid.Hex(),
+		"typ": mfaChallengeTokTyp,
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := map[string]string{
+		"expired":      expired,
+		"access token": access,
+		"other key":    otherKey,
+		"alg none":     unsigned,
+		"tampered":     valid[:len(valid)-2] + "xx",
+		"garbage":      "not-a-token",
+	}
+	for name, token := range tests {
+		if _, err := parseMFAChallenge(token); err == nil {
+			t.Errorf("%s: parseMFAChallenge accepted the token", name)
+		}
+	}
+}
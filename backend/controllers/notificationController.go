@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+const (
+	notificationPageDefault = 50
+	notificationPageMax     = 200
+)
+
+func getNotificationCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("notifications")
+}
+
+// notifyUser บันทึกการแจ้งเตือนในแอป และส่งอีเมลด้วยถ้าผู้ใช้เปิดการแจ้งเตือนทางอีเมลและยืนยันอีเมลแล้ว
+// (ยังไม่มีช่องทาง push ในระบบ แอปดึงรายการจาก GET /me/notifications)
+func notifyUser(user models.User, kind, title, body string, data map[string]string) error {
+	n := models.Notification{
+		UserID:    user.ID,
+		Type:      kind,
+		Title:     title,
+		Body:      body,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+
+	if user.Notifications.Email && user.Email != "" && user.EmailVerified && config.MailClient != nil {
+		if err := config.MailClient.Send(user.Email, "Lucky Pus - "+title, body+"\n"); err != nil {
+			log.Println("send notification email:", user.ID.Hex(), err)
+		} else {
+			now := time.Now()
+			n.EmailedAt = &now
+		}
+	}
+
+	_, err := getNotificationCollection().InsertOne(context.Background(), n)
+	return err
+}
+
+// ====================== Notifications ======================
+
+// ListNotifications คืนการแจ้งเตือนล่าสุดก่อน ?unread=true เฉพาะที่ยังไม่อ่าน
+func ListNotifications(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	limit := int64(notificationPageDefault)
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 1 || n > notificationPageMax {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	filter := bson.M{"user_id": uid}
+	if c.Query("unread") == "true" {
+		filter["read_at"] = nil
+	}
+
+	cursor, err := getNotificationCollection().Find(context.Background(), filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get notifications"})
+		return
+	}
+	items := []models.Notification{}
+	if err := cursor.All(context.Background(), &items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse notifications"})
+		return
+	}
+
+	unread, _ := getNotificationCollection().CountDocuments(context.Background(), bson.M{"user_id": uid, "read_at": nil})
+	c.JSON(http.StatusOK, gin.H{"unread": unread, "items": items})
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	result, err := getNotificationCollection().UpdateOne(context.Background(),
+		bson.M{"_id": objID, "user_id": uid, "read_at": nil},
+		bson.M{"$set": bson.M{"read_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update notification"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		count, _ := getNotificationCollection().CountDocuments(context.Background(), bson.M{"_id": objID, "user_id": uid})
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Marked as read"})
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	result, err := getNotificationCollection().UpdateMany(context.Background(),
+		bson.M{"user_id": uid, "read_at": nil},
+		bson.M{"$set": bson.M{"read_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Marked all as read", "updated": result.ModifiedCount})
+}
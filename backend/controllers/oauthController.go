@@ -0,0 +1,382 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+func getIdentityCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("identities")
+}
+
+type oauthLoginRequest struct {
+	IDToken  string `json:"id_token" binding:"required"`
+	Nonce    string `json:"nonce" binding:"required"`
+	DeviceID string `json:"device_id"`
+	Name     string `json:"name"`
+	// ดู Login: ใช้รวมบัญชีไม่ระบุตัวตนบนอุปกรณ์นี้เข้ากับบัญชีที่เข้าสู่ระบบ
+	AnonymousRefreshToken string `json:"anonymous_refresh_token"`
+}
+
+func getOIDCNonceCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("oidc_nonces")
+}
+
+// EnsureIdentityIndexes กันการผูกบัญชีภายนอกเดียวกันซ้ำเมื่อมีคำขอพร้อมกัน
+// และให้ nonce ที่ไม่ได้ใช้หมดอายุเอง
+func EnsureIdentityIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := getIdentityCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println("create identity indexes (check for duplicate provider subjects):", err)
+	}
+
+	_, err = getOIDCNonceCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Println("create oidc nonce indexes:", err)
+	}
+}
+
+// issueOIDCNonce ออก nonce ใช้ครั้งเดียว เก็บเฉพาะ hash ไว้ที่เซิร์ฟเวอร์
+func issueOIDCNonce() (string, error) {
+	nonce, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	_, err = getOIDCNonceCollection().InsertOne(context.Background(), bson.M{
+		"_id":        sha256Hex(nonce),
+		"created_at": now,
+		"expires_at": now.Add(oidcNonceTTL),
+	})
+	return nonce, err
+}
+
+// consumeOIDCNonce ลบ nonce ที่เซิร์ฟเวอร์ออกให้และยังไม่หมดอายุ
+// คืน mongo.ErrNoDocuments เมื่อไม่ได้ออกให้ ถูกใช้ไปแล้ว หรือหมดอายุ
+func consumeOIDCNonce(nonce string) error {
+	if nonce == "" {
+		return mongo.ErrNoDocuments
+	}
+	return getOIDCNonceCollection().FindOneAndDelete(context.Background(), bson.M{
+		"_id":        sha256Hex(nonce),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Err()
+}
+
+// findIdentityUser คืนผู้ใช้ที่ผูกกับ provider + subject และบันทึกเวลาเข้าสู่ระบบล่าสุด
+func findIdentityUser(ctx context.Context, provider, subject string) (models.User, error) {
+	var user models.User
+	var identity models.Identity
+	err := getIdentityCollection().FindOneAndUpdate(ctx,
+		bson.M{"provider": provider, "subject": subject},
+		bson.M{"$set": bson.M{"last_login_at": time.Now()}},
+	).Decode(&identity)
+	if err != nil {
+		return user, err
+	}
+	err = getUserCollection().FindOne(ctx, bson.M{"_id": identity.UserID}).Decode(&user)
+	return user, err
+}
+
+// findOrCreateOIDCUser หาผู้ใช้จาก identity ที่ผูกไว้ หากยังไม่เคยผูก
+// จะผูกกับบัญชีที่อีเมลตรงกัน (เมื่อยืนยันอีเมลแล้วทั้งสองฝั่ง) หรือสร้างบัญชีใหม่
+func findOrCreateOIDCUser(provider string, claims oidcClaims) (models.User, error) {
+	ctx := context.Background()
+
+	user, err := findIdentityUser(ctx, provider, claims.Subject)
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	email := normalizeEmail(claims.Email)
+	found := false
+	if email != "" && claims.EmailVerified {
+		err := getUserCollection().FindOne(ctx, bson.M{
+			"email":          emailMatch(email),
+			"email_verified": true,
+		}).Decode(&user)
+		if err == nil {
+			found = true
+		} else if err != mongo.ErrNoDocuments {
+			return user, err
+		}
+	}
+
+	if !found {
+		suffix, err := generateRandomToken(4)
+		if err != nil {
+			return user, err
+		}
+		user = models.User{
+			Username:      provider + "_" + suffix,
+			DisplayName:   claims.Name,
+			EmailVerified: claims.EmailVerified && email != "",
+			CreatedAt:     primitive.NewDateTimeFromTime(time.Now()),
+		}
+		// ไม่ใช้อีเมลที่ซ้ำกับบัญชีอื่น เพื่อไม่ให้ชนกับการตรวจสอบอีเมลซ้ำตอนสมัคร
+		if email != "" {
+			if count, _ := getUserCollection().CountDocuments(ctx, bson.M{"email": emailMatch(email)}); count == 0 {
+				user.Email = email
+			} else {
+				user.EmailVerified = false
+			}
+		}
+		if user.EmailVerified {
+			user.EmailVerifiedAt = user.CreatedAt
+		}
+
+		res, err := getUserCollection().InsertOne(ctx, user)
+		if mongo.IsDuplicateKeyError(err) && user.Email != "" {
+			// มีบัญชีอื่นใช้อีเมลนี้พร้อมกัน สร้างบัญชีโดยไม่ใส่อีเมลเหมือนกรณีที่ตรวจพบก่อน
+			user.Email, user.EmailVerified, user.EmailVerifiedAt = "", false, 0
+			res, err = getUserCollection().InsertOne(ctx, user)
+		}
+		if err != nil {
+			return user, err
+		}
+		user.ID = res.InsertedID.(primitive.ObjectID)
+	}
+
+	err = linkIdentity(user.ID, provider, claims)
+	if mongo.IsDuplicateKeyError(err) {
+		// คำขออื่นผูก identity นี้ไปพร้อมกัน ใช้บัญชีนั้นแทนและลบบัญชีที่เพิ่งสร้าง
+		if !found {
+			_, _ = getUserCollection().DeleteOne(ctx, bson.M{"_id": user.ID})
+		}
+		return findIdentityUser(ctx, provider, claims.Subject)
+	}
+	return user, err
+}
+
+func linkIdentity(userID primitive.ObjectID, provider string, claims oidcClaims) error {
+	now := time.Now()
+	_, err := getIdentityCollection().InsertOne(context.Background(), models.Identity{
+		UserID:        userID,
+		Provider:      provider,
+		Subject:       claims.Subject,
+		Email:         normalizeEmail(claims.Email),
+		EmailVerified: claims.EmailVerified,
+		CreatedAt:     now,
+		LastLoginAt:   now,
+	})
+	return err
+}
+
+// verifyOAuthRequest ตรวจ ID token แล้วใช้ nonce ที่ส่งมา ซึ่งต้องเป็น nonce ที่ IssueOAuthNonce ออกให้
+// ตอบกลับข้อผิดพลาดและคืน false เมื่อไม่ผ่าน
+func verifyOAuthRequest(c *gin.Context, provider string, req oauthLoginRequest) (oidcClaims, bool) {
+	claims, err := verifyIDToken(provider, req.IDToken, req.Nonce)
+	if err == errUnknownProvider {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ไม่รองรับผู้ให้บริการนี้"})
+		return claims, false
+	} else if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ID token ไม่ถูกต้องหรือหมดอายุ"})
+		return claims, false
+	}
+
+	if err := consumeOIDCNonce(req.Nonce); err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "nonce ไม่ถูกต้อง ถูกใช้แล้ว หรือหมดอายุ"})
+		return claims, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถตรวจสอบ nonce ได้"})
+		return claims, false
+	}
+	return claims, true
+}
+
+// ====================== OAuth / OIDC Login ======================
+
+// IssueOAuthNonce ออก nonce ให้ client ส่งไปกับคำขอ ID token (Apple ใช้ sha256 ของค่านี้)
+// แล้วส่งค่าเดิมกลับมาที่ OAuthLogin หรือ LinkIdentity ภายใน oidcNonceTTL
+func IssueOAuthNonce(c *gin.Context) {
+	nonce, err := issueOIDCNonce()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถออก nonce ได้"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"nonce":      nonce,
+		"expires_in": int(oidcNonceTTL.Seconds()),
+	})
+}
+
+func OAuthLogin(c *gin.Context) {
+	provider := c.Param("provider")
+
+	var req oauthLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	claims, ok := verifyOAuthRequest(c, provider, req)
+	if !ok {
+		return
+	}
+
+	user, err := findOrCreateOIDCUser(provider, claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเข้าสู่ระบบได้"})
+		return
+	}
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": accountDisabledMessage})
+		return
+	}
+
+	if user.MFAEnabled {
+		mfaToken, err := signMFAChallenge(user.ID, req.DeviceID, req.Name, anonymousTokenHash(req.AnonymousRefreshToken))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเข้าสู่ระบบได้"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int(mfaChallengeTTL.Seconds()),
+			"message":      "กรุณากรอกรหัสยืนยันตัวตนสองชั้น",
+		})
+		return
+	}
+
+	respondWithLoginTokens(c, user, req.DeviceID, req.Name, anonymousTokenHash(req.AnonymousRefreshToken))
+}
+
+// ====================== Linked Identities ======================
+func ListIdentities(c *gin.Context) {
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	cursor, err := getIdentityCollection().Find(context.Background(), bson.M{"user_id": user.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get identities"})
+		return
+	}
+	identities := []models.Identity{}
+	if err := cursor.All(context.Background(), &identities); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse identities"})
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+func LinkIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+
+	var req oauthLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	claims, ok := verifyOAuthRequest(c, provider, req)
+	if !ok {
+		return
+	}
+
+	count, err := getIdentityCollection().CountDocuments(context.Background(), bson.M{
+		"$or": []bson.M{
+			{"provider": provider, "subject": claims.Subject},
+			{"provider": provider, "user_id": user.ID},
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเชื่อมบัญชีได้"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "บัญชีนี้ถูกเชื่อมไว้แล้ว"})
+		return
+	}
+
+	if err := linkIdentity(user.ID, provider, claims); mongo.IsDuplicateKeyError(err) {
+		// ถูกผูกไปพร้อมกัน สำเร็จเมื่อเป็นบัญชีนี้เอง (เช่นกดซ้ำ)
+		var identity models.Identity
+		err := getIdentityCollection().FindOne(context.Background(),
+			bson.M{"provider": provider, "subject": claims.Subject},
+		).Decode(&identity)
+		if err != nil || identity.UserID != user.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "บัญชีนี้ถูกเชื่อมไว้แล้ว"})
+			return
+		}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเชื่อมบัญชีได้"})
+		return
+	}
+
+	// บัญชีไม่ระบุตัวตนที่เชื่อมกับผู้ให้บริการแล้วถือเป็นบัญชีเต็มรูปแบบ
+	if isAnonymousUser(user) {
+		_, _ = getUserCollection().UpdateOne(context.Background(),
+			bson.M{"_id": user.ID},
+			bson.M{"$set": bson.M{"anonymous": false}},
+		)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "เชื่อมบัญชีสำเร็จ", "provider": provider})
+}
+
+func UnlinkIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	// ต้องเหลือวิธีเข้าสู่ระบบอย่างน้อยหนึ่งวิธี
+	others, err := getIdentityCollection().CountDocuments(context.Background(), bson.M{
+		"user_id":  user.ID,
+		"provider": bson.M{"$ne": provider},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยกเลิกการเชื่อมบัญชีได้"})
+		return
+	}
+	if user.Password == "" && others == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "กรุณาตั้งรหัสผ่านหรือเชื่อมบัญชีอื่นก่อนยกเลิกการเชื่อม"})
+		return
+	}
+
+	result, err := getIdentityCollection().DeleteOne(context.Background(), bson.M{
+		"user_id":  user.ID,
+		"provider": provider,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถยกเลิกการเชื่อมบัญชีได้"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ไม่พบบัญชีที่เชื่อมไว้"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ยกเลิกการเชื่อมบัญชีสำเร็จ"})
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"luckyPus/config"
+)
+
+const (
+	jwksCacheTTL = time.Hour
+	// ดึง JWKS ใหม่ได้ไม่เกินหนึ่งครั้งต่อช่วงนี้ กัน client ส่ง kid มั่ว ๆ ให้เซิร์ฟเวอร์ยิง IdP รัว ๆ
+	jwksMinRefetch = time.Minute
+	// เวลาที่ client มีเพื่อขอ ID token และส่งกลับมาพร้อม nonce ที่ออกให้
+	oidcNonceTTL = 10 * time.Minute
+)
+
+var errUnknownProvider = errors.New("unknown oidc provider")
+
+type oidcClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type jwksCacheEntry struct {
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	attemptedAt time.Time // ครั้งล่าสุดที่พยายามดึง รวมครั้งที่ล้มเหลว
+}
+
+var (
+	jwksMu    sync.Mutex
+	jwksCache = map[string]jwksCacheEntry{}
+)
+
+// verifyIDToken ตรวจลายเซ็นของ ID token กับ JWKS ของผู้ให้บริการ
+// รวมถึง iss, aud, exp และ nonce ซึ่งต้องส่งมาเสมอ
+func verifyIDToken(providerName, rawToken, nonce string) (oidcClaims, error) {
+	var result oidcClaims
+
+	provider, ok := config.OIDCProviders[providerName]
+	if !ok {
+		return result, errUnknownProvider
+	}
+
+	token, err := jwt.Parse(rawToken, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return lookupJWK(provider.JWKSURL, kid)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return result, errors.New("invalid id token")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != provider.Issuer {
+		return result, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !audienceAllowed(claims["aud"], provider.ClientIDs) {
+		return result, errors.New("unexpected audience")
+	}
+	if _, hasExp := claims["exp"]; !hasExp {
+		return result, errors.New("id token has no exp")
+	}
+
+	// Apple แนะนำให้ส่ง sha256(nonce) ไปตอนขอ token จึงยอมรับทั้งค่าดิบและ hash
+	// nonce ต้องเป็นค่าที่เซิร์ฟเวอร์ออกให้ ผู้เรียกต้อง consumeOIDCNonce ต่อ
+	// (client จึงส่งค่า hash ที่อยู่ใน token กลับมาแทนไม่ได้)
+	got, _ := claims["nonce"].(string)
+	if nonce == "" || got == "" || (got != nonce && got != sha256Hex(nonce)) {
+		return result, errors.New("nonce mismatch")
+	}
+
+	result.Subject, _ = claims["sub"].(string)
+	if result.Subject == "" {
+		return result, errors.New("id token has no sub")
+	}
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+
+	// Apple ส่ง email_verified เป็น string "true"
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = v
+	case string:
+		result.EmailVerified = v == "true"
+	}
+
+	return result, nil
+}
+
+func audienceAllowed(aud interface{}, clientIDs []string) bool {
+	var auds []string
+	switch v := aud.(type) {
+	case string:
+		auds = []string{v}
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				auds = append(auds, s)
+			}
+		}
+	}
+
+	for _, a := range auds {
+		for _, id := range clientIDs {
+			if a == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupJWK คืน public key ตาม kid จาก cache และดึง JWKS ใหม่เมื่อไม่พบหรือหมดอายุ
+// (ผู้ให้บริการหมุนเวียนกุญแจเป็นระยะ) แต่ไม่เกินหนึ่งครั้งต่อ jwksMinRefetch
+func lookupJWK(jwksURL, kid string) (interface{}, error) {
+	jwksMu.Lock()
+	defer jwksMu.Unlock()
+
+	entry, cached := jwksCache[jwksURL]
+	if cached && time.Since(entry.fetchedAt) < jwksCacheTTL {
+		if key, ok := entry.keys[kid]; ok {
+			return key, nil
+		}
+	}
+
+	if cached && time.Since(entry.attemptedAt) < jwksMinRefetch {
+		if key, ok := entry.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	entry.attemptedAt = time.Now()
+	keys, err := fetchJWKS(jwksURL)
+	if err != nil {
+		// เก็บเวลาที่ล้มเหลวไว้ด้วย และใช้กุญแจชุดเดิมต่อไปจนกว่าจะดึงใหม่ได้
+		jwksCache[jwksURL] = entry
+		if key, ok := entry.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	entry.keys, entry.fetchedAt = keys, entry.attemptedAt
+	jwksCache[jwksURL] = entry
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func fetchJWKS(jwksURL string) (map[string]interface{}, error) {
+	var body []byte
+	if path, isFile := strings.CutPrefix(jwksURL, "file://"); isFile {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	} else {
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(jwksURL)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks status %d", resp.StatusCode)
+		}
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"luckyPus/config"
+)
+
+const (
+	testIssuer   = "https://issuer.test"
+	testClientID = "com.example.lucky"
+)
+
+// oidcTestIssuer คือ issuer ทดสอบที่เก็บ JWKS เป็นไฟล์ (file://) เหมือน provider "test" ใน development
+type oidcTestIssuer struct {
+	t    *testing.T
+	path string
+	keys map[string]*rsa.PrivateKey
+}
+
+// useOIDCTestIssuer เปิด provider "test" ผ่าน LoadOIDC และล้าง cache ของ JWKS ระหว่างเทสต์
+func useOIDCTestIssuer(t *testing.T) *oidcTestIssuer {
+	t.Helper()
+	iss := &oidcTestIssuer{
+		t:    t,
+		path: filepath.Join(t.TempDir(), "jwks.json"),
+		keys: map[string]*rsa.PrivateKey{},
+	}
+	iss.addKey("k1")
+
+	t.Setenv("OIDC_TEST_CLIENT_IDS", testClientID)
+	t.Setenv("OIDC_TEST_ISSUER", testIssuer)
+	t.Setenv("OIDC_TEST_JWKS_URL", "file://"+iss.path)
+	prevProviders := config.OIDCProviders
+	config.LoadOIDC()
+
+	jwksMu.Lock()
+	prevCache := jwksCache
+	jwksCache = map[string]jwksCacheEntry{}
+	jwksMu.Unlock()
+
+	t.Cleanup(func() {
+		config.OIDCProviders = prevProviders
+		jwksMu.Lock()
+		jwksCache = prevCache
+		jwksMu.Unlock()
+	})
+	return iss
+}
+
+// addKey สร้างกุญแจใหม่และเขียน JWKS ที่มีกุญแจทั้งหมดลงไฟล์
+func (iss *oidcTestIssuer) addKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		iss.t.Fatal(err)
+	}
+	iss.keys[kid] = key
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for id, k := range iss.keys {
+		set.Keys = append(set.Keys, map[string]string{
+			"kid": id,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+	b, _ := json.Marshal(set)
+	if err := os.WriteFile(iss.path, b, 0o600); err != nil {
+		iss.t.Fatal(err)
+	}
+}
+
+func (iss *oidcTestIssuer) validClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            testIssuer,
+		"aud":            testClientID,
+		"sub":            "subject-1",
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          "Someone@Example.com",
+		"email_verified": "true",
+	}
+}
+
+func (iss *oidcTestIssuer) sign(kid string, claims jwt.MapClaims) string {
+	return signWithKid(iss.t, iss.keys[kid], kid, claims)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	iss := useOIDCTestIssuer(t)
+	nonce := "server-issued-nonce"
+
+	claims, err := verifyIDToken("test", iss.sign("k1", iss.validClaims(nonce)), nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "Someone@Example.com" || !claims.EmailVerified {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// Apple ใส่ sha256 ของ nonce ใน token ส่วน client ส่งค่าดิบที่ได้จากเซิร์ฟเวอร์
+	hashed := iss.validClaims(sha256Hex(nonce))
+	if _, err := verifyIDToken("test", iss.sign("k1", hashed), nonce); err != nil {
+		t.Fatalf("hashed nonce: %v", err)
+	}
+
+	if _, err := verifyIDToken("apple", iss.sign("k1", iss.validClaims(nonce)), nonce); err != errUnknownProvider {
+		t.Fatalf("unconfigured provider: err = %v", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	iss := useOIDCTestIssuer(t)
+	nonce := "server-issued-nonce"
+
+	with := func(key string, value interface{}) jwt.MapClaims {
+		c := iss.validClaims(nonce)
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, iss.validClaims(nonce))
+	forged.Header["kid"] = "k1"
+	forgedToken, _ := forged.SignedString(other)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, iss.validClaims(nonce))
+	hmac.Header["kid"] = "k1"
+	hmacToken, _ := hmac.SignedString([]byte("secret"))
+
+	cases := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"wrong signature", forgedToken, nonce},
+		{"hmac", hmacToken, nonce},
+		{"unknown kid", signWithKid(t, iss.keys["k1"], "nope", iss.validClaims(nonce)), nonce},
+		{"issuer", iss.sign("k1", with("iss", "https://evil.test")), nonce},
+		{"audience", iss.sign("k1", with("aud", "other-app")), nonce},
+		{"audience list", iss.sign("k1", with("aud", []string{"other-app"})), nonce},
+		{"expired", iss.sign("k1", with("exp", time.Now().Add(-time.Minute).Unix())), nonce},
+		{"no exp", iss.sign("k1", with("exp", nil)), nonce},
+		{"no sub", iss.sign("k1", with("sub", nil)), nonce},
+		{"nonce mismatch", iss.sign("k1", iss.validClaims(nonce)), "other-nonce"},
+		{"no nonce claim", iss.sign("k1", with("nonce", nil)), nonce},
+		{"empty nonce", iss.sign("k1", with("nonce", "")), ""},
+	}
+	for _, tc := range cases {
+		if _, err := verifyIDToken("test", tc.token, tc.nonce); err == nil {
+			t.Errorf("%s: accepted", tc.name)
+		}
+	}
+}
+
+func signWithKid(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	iss := useOIDCTestIssuer(t)
+	nonce := "server-issued-nonce"
+
+	if _, err := verifyIDToken("test", iss.sign("k1", iss.validClaims(nonce)), nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	// ผู้ให้บริการเพิ่มกุญแจใหม่ แต่เพิ่งดึง JWKS ไป จึงยังไม่ดึงซ้ำภายใน jwksMinRefetch
+	iss.addKey("k2")
+	if _, err := verifyIDToken("test", iss.sign("k2", iss.validClaims(nonce)), nonce); err == nil {
+		t.Fatal("refetched JWKS within jwksMinRefetch")
+	}
+
+	jwksMu.Lock()
+	for url, entry := range jwksCache {
+		entry.attemptedAt = entry.attemptedAt.Add(-jwksMinRefetch)
+		jwksCache[url] = entry
+	}
+	jwksMu.Unlock()
+
+	if _, err := verifyIDToken("test", iss.sign("k2", iss.validClaims(nonce)), nonce); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if _, err := verifyIDToken("test", iss.sign("k1", iss.validClaims(nonce)), nonce); err != nil {
+		t.Fatalf("previous key: %v", err)
+	}
+}
@@ -0,0 +1,578 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+// ราคาสลากกินแบ่งรัฐบาลต่อใบ ใช้คำนวณต้นทุน
+const lotteryTicketPrice = 80
+
+const poolNameMaxLength = 50
+
+func getPoolCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("pools")
+}
+
+// findPool โหลดกลุ่มที่ผู้ใช้เป็นสมาชิก activeOnly บังคับว่าต้องตอบรับคำเชิญแล้ว
+func findPool(c *gin.Context, uid primitive.ObjectID, activeOnly bool) (models.Pool, bool) {
+	var pool models.Pool
+	poolID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pool ID"})
+		return pool, false
+	}
+
+	err = getPoolCollection().FindOne(context.Background(), bson.M{"_id": poolID, "members.user_id": uid}).Decode(&pool)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pool not found"})
+		return pool, false
+	}
+
+	if m, _ := pool.Member(uid); activeOnly && m.Status != models.PoolMemberActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Accept the pool invitation first"})
+		return pool, false
+	}
+	return pool, true
+}
+
+// poolIDForTicket ตรวจ pool_id ที่ส่งมากับการเพิ่มสลาก คืน NilObjectID เมื่อเป็นสลากส่วนตัว
+func poolIDForTicket(c *gin.Context, raw string, uid primitive.ObjectID) (primitive.ObjectID, bool) {
+	if raw == "" {
+		return primitive.NilObjectID, true
+	}
+	poolID, err := primitive.ObjectIDFromHex(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pool ID"})
+		return poolID, false
+	}
+	count, err := getPoolCollection().CountDocuments(context.Background(), bson.M{
+		"_id":     poolID,
+		"members": bson.M{"$elemMatch": bson.M{"user_id": uid, "status": models.PoolMemberActive}},
+	})
+	if err != nil || count == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this pool"})
+		return poolID, false
+	}
+	return poolID, true
+}
+
+// activePoolsOf คืนกลุ่มที่ผู้ใช้เป็นสมาชิก active
+func activePoolsOf(uid primitive.ObjectID) ([]models.Pool, error) {
+	cursor, err := getPoolCollection().Find(context.Background(), bson.M{
+		"members": bson.M{"$elemMatch": bson.M{"user_id": uid, "status": models.PoolMemberActive}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	pools := []models.Pool{}
+	err = cursor.All(context.Background(), &pools)
+	return pools, err
+}
+
+// lotteryScope คือ filter ของสลากที่ผู้ใช้เข้าถึงได้ ทั้งที่บันทึกเองและสลากของกลุ่มที่เป็นสมาชิก
+func lotteryScope(uid primitive.ObjectID) (bson.M, error) {
+	pools, err := activePoolsOf(uid)
+	if err != nil {
+		return nil, err
+	}
+	if len(pools) == 0 {
+		return bson.M{"user_id": uid}, nil
+	}
+	poolIDs := make([]primitive.ObjectID, len(pools))
+	for i, p := range pools {
+		poolIDs[i] = p.ID
+	}
+	return bson.M{"$or": []bson.M{{"user_id": uid}, {"pool_id": bson.M{"$in": poolIDs}}}}, nil
+}
+
+type poolTotals struct {
+	Tickets int `json:"tickets"`
+	Wins    int `json:"wins"`
+	Cost    int `json:"cost"`
+	Prize   int `json:"prize"`
+}
+
+// poolTicketTotals รวมจำนวนใบ ต้นทุน และเงินรางวัลของสลากในกลุ่ม (ไม่รวมที่อยู่ในถังขยะ)
+func poolTicketTotals(poolID primitive.ObjectID) (poolTotals, error) {
+	var t poolTotals
+	cursor, err := getLotteryCollection().Find(context.Background(), bson.M{"pool_id": poolID, "deleted_at": nil})
+	if err != nil {
+		return t, err
+	}
+	var lotteries []models.Lottery
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		return t, err
+	}
+
+	for _, l := range lotteries {
+		qty := l.Quantity
+		if qty <= 0 {
+			qty = 1
+		}
+		t.Tickets += qty
+		t.Cost += qty * lotteryTicketPrice
+		if prize, win := prizeForStatus(l.Status); win {
+			t.Wins += qty
+			t.Prize += prize * qty
+		}
+	}
+	return t, nil
+}
+
+// ====================== Pools ======================
+func CreatePool(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" || utf8.RuneCountInString(name) > poolNameMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1-50 characters"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	pool := models.Pool{
+		Name:    name,
+		OwnerID: user.ID,
+		Members: []models.PoolMember{{
+			UserID:    user.ID,
+			Username:  user.Username,
+			Share:     100,
+			Status:    models.PoolMemberActive,
+			InvitedAt: now,
+			JoinedAt:  &now,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	res, err := getPoolCollection().InsertOne(context.Background(), pool)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot create pool"})
+		return
+	}
+	pool.ID = res.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusOK, pool)
+}
+
+// ListPools คืนกลุ่มที่ผู้ใช้เป็นสมาชิกหรือได้รับคำเชิญ
+func ListPools(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	cursor, err := getPoolCollection().Find(context.Background(),
+		bson.M{"members.user_id": uid},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return
+	}
+	pools := []models.Pool{}
+	if err := cursor.All(context.Background(), &pools); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse pools"})
+		return
+	}
+	c.JSON(http.StatusOK, pools)
+}
+
+func GetPool(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	pool, ok := findPool(c, uid, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, pool)
+}
+
+// DeletePool ลบกลุ่ม (เจ้าของเท่านั้น) สลากของกลุ่มจะกลับเป็นสลากส่วนตัวของผู้บันทึก
+func DeletePool(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	pool, ok := findPool(c, uid, true)
+	if !ok {
+		return
+	}
+	if pool.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the pool owner can delete the pool"})
+		return
+	}
+
+	if err := detachPoolTickets(pool.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot delete pool"})
+		return
+	}
+	if _, err := getPoolCollection().DeleteOne(context.Background(), bson.M{"_id": pool.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot delete pool"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pool deleted"})
+}
+
+// detachPoolTickets คืนสลากของกลุ่มให้เป็นสลากส่วนตัวของผู้บันทึก
+// ถ้าผู้บันทึกมีสลากส่วนตัวงวดและเลขเดียวกันอยู่แล้ว จะรวมเข้ากับใบเดิมเหมือน RestoreLottery
+func detachPoolTickets(poolID primitive.ObjectID) error {
+	cursor, err := getLotteryCollection().Find(context.Background(), bson.M{"pool_id": poolID})
+	if err != nil {
+		return err
+	}
+	var lotteries []models.Lottery
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		return err
+	}
+
+tickets:
+	for _, l := range lotteries {
+		// ลองสองรอบ: ถ้าผู้บันทึกสร้างใบเดียวกันระหว่างนี้ unique index จะกันไว้ แล้วรอบที่สองจะรวมเข้าใบนั้น
+		for attempt := 0; attempt < 2; attempt++ {
+			if l.DeletedAt == nil {
+				var existing models.Lottery
+				err := getLotteryCollection().FindOne(context.Background(),
+					lotteryDuplicateFilter(l.UserID, nil, l.Round, l.Number),
+				).Decode(&existing)
+				if err == nil {
+					if _, err := mergeDuplicateLottery(nil, existing, l); err != nil {
+						return err
+					}
+					continue tickets
+				} else if err != mongo.ErrNoDocuments {
+					return err
+				}
+			}
+
+			_, err := getLotteryCollection().UpdateOne(context.Background(),
+				bson.M{"_id": l.ID},
+				bson.M{"$unset": bson.M{"pool_id": ""}, "$set": bson.M{"updated_at": time.Now()}},
+			)
+			if err == nil {
+				continue tickets
+			} else if !mongo.IsDuplicateKeyError(err) || attempt > 0 {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ====================== Members ======================
+
+// InvitePoolMember เชิญผู้ใช้ด้วย username (เจ้าของเท่านั้น) share เริ่มต้นเป็น 0 จนกว่าจะกำหนดสัดส่วน
+func InvitePoolMember(c *gin.Context) {
+	var input struct {
+		Username string  `json:"username" binding:"required"`
+		Share    float64 `json:"share"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Share < 0 || input.Share > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "share must be between 0 and 100"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	pool, ok := findPool(c, uid, true)
+	if !ok {
+		return
+	}
+	if pool.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the pool owner can invite members"})
+		return
+	}
+
+	var invitee models.User
+	err := getUserCollection().FindOne(context.Background(), bson.M{
+		"username": strings.TrimSpace(input.Username),
+	}).Decode(&invitee)
+	if err != nil || invitee.Disabled || invitee.DeleteAfter != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if _, exists := pool.Member(invitee.ID); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member or invited"})
+		return
+	}
+
+	member := models.PoolMember{
+		UserID:    invitee.ID,
+		Username:  invitee.Username,
+		Share:     input.Share,
+		Status:    models.PoolMemberInvited,
+		InvitedBy: uid,
+		InvitedAt: time.Now(),
+	}
+	_, err = getPoolCollection().UpdateOne(context.Background(),
+		bson.M{"_id": pool.ID, "members.user_id": bson.M{"$ne": invitee.ID}},
+		bson.M{"$push": bson.M{"members": member}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot invite member"})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+func AcceptPoolInvite(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	pool, ok := findPool(c, uid, false)
+	if !ok {
+		return
+	}
+	if m, _ := pool.Member(uid); m.Status != models.PoolMemberInvited {
+		c.JSON(http.StatusConflict, gin.H{"error": "No pending invitation"})
+		return
+	}
+
+	now := time.Now()
+	_, err := getPoolCollection().UpdateOne(context.Background(),
+		bson.M{"_id": pool.ID, "members.user_id": uid},
+		bson.M{"$set": bson.M{
+			"members.$.status":    models.PoolMemberActive,
+			"members.$.joined_at": now,
+			"updated_at":          now,
+		}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot accept invitation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Joined pool"})
+}
+
+// LeavePool ปฏิเสธคำเชิญหรือออกจากกลุ่ม เจ้าของต้องลบกลุ่มแทน
+func LeavePool(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	pool, ok := findPool(c, uid, false)
+	if !ok {
+		return
+	}
+	if pool.OwnerID == uid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner cannot leave the pool; delete it instead"})
+		return
+	}
+
+	if err := removePoolMember(pool, uid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot leave pool"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Left pool"})
+}
+
+func RemovePoolMember(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	memberID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	pool, ok := findPool(c, uid, true)
+	if !ok {
+		return
+	}
+	if pool.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the pool owner can remove members"})
+		return
+	}
+	if memberID == uid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner cannot be removed"})
+		return
+	}
+	if _, exists := pool.Member(memberID); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if err := removePoolMember(pool, memberID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot remove member"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// leaveAllPools ใช้ตอนลบบัญชี กลุ่มที่เป็นเจ้าของจะถูกลบ ส่วนกลุ่มอื่นสลากที่ผู้ใช้บันทึกไว้จะโอนให้เจ้าของกลุ่ม
+func leaveAllPools(uid primitive.ObjectID) error {
+	cursor, err := getPoolCollection().Find(context.Background(), bson.M{"members.user_id": uid})
+	if err != nil {
+		return err
+	}
+	var pools []models.Pool
+	if err := cursor.All(context.Background(), &pools); err != nil {
+		return err
+	}
+
+	for _, p := range pools {
+		if p.OwnerID == uid {
+			if err := detachPoolTickets(p.ID); err != nil {
+				return err
+			}
+			if _, err := getPoolCollection().DeleteOne(context.Background(), bson.M{"_id": p.ID}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := removePoolMember(p, uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removePoolMember เอาสมาชิกออกจากกลุ่ม สลากของกลุ่มที่สมาชิกคนนั้นบันทึกไว้จะโอนให้เจ้าของกลุ่ม
+// สิทธิ์ของสลากกลุ่มดูจากการเป็นสมาชิก ผู้ที่ออกไปแล้วจึงไม่ควรเป็นผู้บันทึกอยู่
+func removePoolMember(pool models.Pool, memberID primitive.ObjectID) error {
+	_, err := getLotteryCollection().UpdateMany(context.Background(),
+		bson.M{"pool_id": pool.ID, "user_id": memberID},
+		bson.M{"$set": bson.M{"user_id": pool.OwnerID, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = getPoolCollection().UpdateOne(context.Background(),
+		bson.M{"_id": pool.ID},
+		bson.M{
+			"$pull": bson.M{"members": bson.M{"user_id": memberID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+// SetPoolShares กำหนดสัดส่วนของสมาชิกทุกคน (เจ้าของเท่านั้น) ผลรวมต้องเท่ากับ 100
+func SetPoolShares(c *gin.Context) {
+	var input struct {
+		Shares map[string]float64 `json:"shares" binding:"required"` // user_id -> เปอร์เซ็นต์
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	pool, ok := findPool(c, uid, true)
+	if !ok {
+		return
+	}
+	if pool.OwnerID != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the pool owner can set shares"})
+		return
+	}
+
+	total := 0.0
+	members := make([]models.PoolMember, len(pool.Members))
+	for i, m := range pool.Members {
+		share, ok := input.Shares[m.UserID.Hex()]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shares must include every member", "user_id": m.UserID.Hex()})
+			return
+		}
+		if share < 0 || share > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "share must be between 0 and 100", "user_id": m.UserID.Hex()})
+			return
+		}
+		m.Share = share
+		members[i] = m
+		total += share
+	}
+	if len(input.Shares) != len(pool.Members) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "shares contains users who are not members"})
+		return
+	}
+	if math.Abs(total-100) > 0.01 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "shares must add up to 100"})
+		return
+	}
+
+	_, err := getPoolCollection().UpdateOne(context.Background(),
+		bson.M{"_id": pool.ID},
+		bson.M{"$set": bson.M{"members": members, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update shares"})
+		return
+	}
+	pool.Members = members
+	c.JSON(http.StatusOK, pool)
+}
+
+// ====================== Pool Stats ======================
+
+// GetPoolStats สรุปต้นทุนและเงินรางวัลของกลุ่ม และส่วนของสมาชิกแต่ละคนตามสัดส่วน
+func GetPoolStats(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	pool, ok := findPool(c, uid, true)
+	if !ok {
+		return
+	}
+
+	totals, err := poolTicketTotals(pool.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get lotteries"})
+		return
+	}
+
+	members := []gin.H{}
+	for _, m := range pool.Members {
+		if m.Status != models.PoolMemberActive {
+			continue
+		}
+		share := pool.ActiveShare(m.UserID)
+		cost := roundBaht(float64(totals.Cost) * share)
+		prize := roundBaht(float64(totals.Prize) * share)
+		members = append(members, gin.H{
+			"user_id":  m.UserID,
+			"username": m.Username,
+			"share":    roundBaht(share * 100),
+			"cost":     cost,
+			"prize":    prize,
+			"net":      roundBaht(prize - cost),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pool_id": pool.ID,
+		"name":    pool.Name,
+		"totals":  totals,
+		"members": members,
+	})
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import "math"
+
+const (
+	// อากรแสตมป์ของสลากกินแบ่งรัฐบาล 0.5% (สลากการกุศลคือ 1%)
+	prizeStampDutyRate = 0.005
+	// เงินรางวัลสลากกินแบ่งรัฐบาลได้รับยกเว้นภาษีเงินได้ จึงไม่มีหัก ณ ที่จ่าย
+	prizeWithholdingRate = 0.0
+	// สิทธิ์ขึ้นเงินรางวัลมีอายุ 2 ปีนับจากวันออกรางวัล
+	claimPeriodYears = 2
+)
+
+// prizeInfo คือเงินรางวัลต่อใบและชื่อภาษาอังกฤษ (ใช้ในรายงาน PDF ที่ฟอนต์มาตรฐานไม่มีอักษรไทย)
+type prizeInfo struct {
+	Amount int
+	Label  string
+}
+
+var prizeTable = map[string]prizeInfo{
+	"ถูกรางวัล รางวัลที่ 1":                {6000000, "1st prize"},
+	"ถูกรางวัล รางวัลข้างเคียงรางวัลที่ 1": {100000, "Adjacent to 1st prize"},
+	"ถูกรางวัล รางวัลที่ 2":                {200000, "2nd prize"},
+	"ถูกรางวัล รางวัลที่ 3":                {80000, "3rd prize"},
+	"ถูกรางวัล รางวัลที่ 4":                {40000, "4th prize"},
+	"ถูกรางวัล รางวัลที่ 5":                {20000, "5th prize"},
+	"ถูกรางวัล รางวัลเลขหน้า 3 ตัว":        {4000, "First 3 digits"},
+	"ถูกรางวัล รางวัลเลขท้าย 3 ตัว":        {4000, "Last 3 digits"},
+	"ถูกรางวัล รางวัลเลขท้าย 2 ตัว":        {2000, "Last 2 digits"},
+}
+
+// prizeForStatus คืนเงินรางวัลต่อใบของสถานะ และ false เมื่อไม่ถูกรางวัล
+func prizeForStatus(status string) (int, bool) {
+	p, ok := prizeTable[status]
+	return p.Amount, ok
+}
+
+func prizeLabel(status string) string {
+	if p, ok := prizeTable[status]; ok {
+		return p.Label
+	}
+	switch status {
+	case statusNoPrize:
+		return "No prize"
+	case statusUnchecked, "":
+		return "Not checked"
+	}
+	return "Won"
+}
+
+// prizePayout คือยอดเงินรางวัลก่อนและหลังหักอากรแสตมป์และภาษี ณ ที่จ่าย
+type prizePayout struct {
+	Gross       int     `json:"gross"`
+	StampDuty   float64 `json:"stamp_duty"`
+	Withholding float64 `json:"withholding"`
+	Net         float64 `json:"net"`
+}
+
+// roundBaht ปัดเป็นทศนิยม 2 ตำแหน่ง (สตางค์)
+func roundBaht(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func payoutFor(gross int) prizePayout {
+	p := prizePayout{
+		Gross:       gross,
+		StampDuty:   roundBaht(float64(gross) * prizeStampDutyRate),
+		Withholding: roundBaht(float64(gross) * prizeWithholdingRate),
+	}
+	p.Net = roundBaht(float64(gross) - p.StampDuty - p.Withholding)
+	return p
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import "testing"
+
+func TestPayoutFor(t *testing.T) {
+	tests := []struct {
+		gross int
+		want  prizePayout
+	}{
+		{6000000, prizePayout{Gross: 6000000, StampDuty: 30000, Net: 5970000}},
+		{100000, prizePayout{Gross: 100000, StampDuty: 500, Net: 99500}},
+		{4000, prizePayout{Gross: 4000, StampDuty: 20, Net: 3980}},
+		{2000, prizePayout{Gross: 2000, StampDuty: 10, Net: 1990}},
+		{0, prizePayout{}},
+	}
+	for _, tt := range tests {
+		if got := payoutFor(tt.gross); got != tt.want {
+			t.Errorf("payoutFor(%d) = %+v, want %+v", tt.gross, got, tt.want)
+		}
+	}
+}
+
+func TestPayoutForRoundsToSatang(t *testing.T) {
+	// 0.5% ของ 2001 คือ 10.005 ต้องปัดเป็นสตางค์
+	got := payoutFor(2001)
+	if got.StampDuty != 10.01 || got.Net != 1990.99 {
+		t.Fatalf("payoutFor(2001) = %+v, want stamp duty 10.01 and net 1990.99", got)
+	}
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"luckyPus/models"
+)
+
+const (
+	defaultLanguage      = "th"
+	defaultTimezone      = "Asia/Bangkok"
+	displayNameMaxLength = 50
+)
+
+var supportedLanguages = map[string]bool{"th": true, "en": true}
+
+func withProfileDefaults(user models.User) models.User {
+	if user.Language == "" {
+		user.Language = defaultLanguage
+	}
+	if user.Timezone == "" {
+		user.Timezone = defaultTimezone
+	}
+	if user.Roles == nil {
+		user.Roles = user.EffectiveRoles()
+	}
+	return user
+}
+
+// ====================== Profile ======================
+func GetMe(c *gin.Context) {
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, withProfileDefaults(user))
+}
+
+func UpdateMe(c *gin.Context) {
+	var input struct {
+		DisplayName   *string                         `json:"display_name"`
+		Email         *string                         `json:"email"`
+		Language      *string                         `json:"language"`
+		Timezone      *string                         `json:"timezone"`
+		Notifications *models.NotificationPreferences `json:"notifications"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	set := bson.M{}
+	errs := map[string]string{}
+	emailChanged := false
+
+	if input.DisplayName != nil {
+		name := strings.TrimSpace(*input.DisplayName)
+		if utf8.RuneCountInString(name) > displayNameMaxLength {
+			errs["display_name"] = "ชื่อที่แสดงต้องยาวไม่เกิน 50 ตัวอักษร"
+		} else {
+			set["display_name"] = name
+		}
+	}
+
+	if input.Email != nil {
+		email := normalizeEmail(*input.Email)
+		if msg := validateEmail(email); msg != "" {
+			errs["email"] = msg
+		} else if email != user.Email {
+			count, err := getUserCollection().CountDocuments(context.Background(), bson.M{
+				"email": emailMatch(email),
+				"_id":   bson.M{"$ne": user.ID},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "เกิดข้อผิดพลาดในการตรวจสอบผู้ใช้"})
+				return
+			}
+			if count > 0 {
+				errs["email"] = "ไม่สามารถใช้อีเมลนี้ได้"
+			} else {
+				set["email"] = email
+				set["email_verified"] = false
+				emailChanged = true
+			}
+		}
+	}
+
+	if input.Language != nil {
+		if !supportedLanguages[*input.Language] {
+			errs["language"] = "ภาษาที่รองรับคือ th และ en"
+		} else {
+			set["language"] = *input.Language
+		}
+	}
+
+	if input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil || *input.Timezone == "" {
+			errs["timezone"] = "เขตเวลาไม่ถูกต้อง"
+		} else {
+			set["timezone"] = *input.Timezone
+		}
+	}
+
+	if input.Notifications != nil {
+		set["notifications"] = *input.Notifications
+	}
+
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง", "fields": errs})
+		return
+	}
+
+	if len(set) > 0 {
+		_, err := getUserCollection().UpdateOne(context.Background(),
+			bson.M{"_id": user.ID},
+			bson.M{"$set": set},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง", "fields": gin.H{"email": "ไม่สามารถใช้อีเมลนี้ได้"}})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถบันทึกข้อมูลได้"})
+			return
+		}
+	}
+
+	var updated models.User
+	if err := getUserCollection().FindOne(context.Background(), bson.M{"_id": user.ID}).Decode(&updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถบันทึกข้อมูลได้"})
+		return
+	}
+
+	if emailChanged {
+		if err := sendVerificationEmail(updated); err != nil {
+			log.Println("send verification email:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, withProfileDefaults(updated))
+}
+
+// ====================== Change Password ======================
+func ChangePassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+		DeviceID        string `json:"device_id"` // อุปกรณ์ที่ยังให้เข้าสู่ระบบอยู่ต่อ
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง"})
+		return
+	}
+
+	user, ok := findCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "บัญชีนี้ยังไม่มีรหัสผ่าน กรุณาอัปเกรดบัญชีก่อน"})
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "รหัสผ่านปัจจุบันไม่ถูกต้อง"})
+		return
+	}
+
+	if msg := validatePassword(req.NewPassword, user.Username, user.Email); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ข้อมูลไม่ถูกต้อง", "fields": gin.H{"new_password": msg}})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเปลี่ยนรหัสผ่านได้"})
+		return
+	}
+
+	_, err = getUserCollection().UpdateOne(context.Background(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"password": string(hash)}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ไม่สามารถเปลี่ยนรหัสผ่านได้"})
+		return
+	}
+
+	// ยกเลิก refresh token ของอุปกรณ์อื่นทั้งหมด
+	_, _ = getDeviceCollection().UpdateMany(context.Background(),
+		bson.M{"user_id": user.ID, "device_id": bson.M{"$ne": req.DeviceID}},
+		bson.M{"$set": bson.M{"token_hash": ""}},
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "เปลี่ยนรหัสผ่านสำเร็จ"})
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ====================== XLSX ======================
+
+// writeXLSX เขียนไฟล์ Excel แบบ sheet เดียวโดยประกอบ Office Open XML เอง
+// ค่า int และ float64 จะเป็นเซลล์ตัวเลข ค่าอื่นเป็นข้อความ (inline string)
+func writeXLSX(w io.Writer, sheetName string, header []string, rows [][]interface{}) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + xmlEscape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	headerRow := make([]interface{}, len(header))
+	for i, h := range header {
+		headerRow[i] = h
+	}
+	for r, row := range append([][]interface{}{headerRow}, rows...) {
+		fmt.Fprintf(&sb, `<row r="%d">`, r+1)
+		for i, v := range row {
+			ref := xlsxColumn(i) + strconv.Itoa(r+1)
+			switch v := v.(type) {
+			case int:
+				fmt.Fprintf(&sb, `<c r="%s"><v>%d</v></c>`, ref, v)
+			case float64:
+				fmt.Fprintf(&sb, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				fmt.Fprintf(&sb, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(fmt.Sprint(v)))
+			}
+		}
+		sb.WriteString(`</row>`)
+	}
+	sb.WriteString(`</sheetData></worksheet>`)
+	if _, err := io.WriteString(fw, sb.String()); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// xlsxColumn แปลงเลขคอลัมน์ (เริ่มที่ 0) เป็นชื่อคอลัมน์ A, B, ..., Z, AA, ...
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// ====================== PDF ======================
+
+const (
+	pdfPageWidth  = 595.0 // A4
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// pdfReport สร้าง PDF แบบข้อความล้วนด้วยฟอนต์มาตรฐาน Helvetica และขึ้นหน้าใหม่ให้อัตโนมัติ
+// ฟอนต์มาตรฐานไม่มีอักษรไทย ตัวอักษรนอก Latin-1 จะถูกแทนด้วย ?
+type pdfReport struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+type pdfCell struct {
+	X    float64
+	Text string
+}
+
+func newPDFReport() *pdfReport {
+	r := &pdfReport{}
+	r.newPage()
+	return r
+}
+
+func (r *pdfReport) newPage() {
+	r.pages = append(r.pages, &bytes.Buffer{})
+	r.y = pdfPageHeight - pdfMargin
+}
+
+// Line เขียนข้อความหนึ่งบรรทัด แต่ละ cell วางที่ตำแหน่ง x ของตัวเอง
+func (r *pdfReport) Line(size float64, bold bool, cells ...pdfCell) {
+	lineHeight := size * 1.4
+	if r.y-lineHeight < pdfMargin {
+		r.newPage()
+	}
+	r.y -= lineHeight
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	page := r.pages[len(r.pages)-1]
+	for _, cell := range cells {
+		fmt.Fprintf(page, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n",
+			font, size, pdfMargin+cell.X, r.y, pdfEscape(cell.Text))
+	}
+}
+
+// Space เว้นบรรทัดว่าง
+func (r *pdfReport) Space(height float64) {
+	r.y -= height
+}
+
+// Rule ขีดเส้นคั่นเต็มความกว้างหน้า
+func (r *pdfReport) Rule() {
+	if r.y-6 < pdfMargin {
+		r.newPage()
+		return
+	}
+	r.y -= 4
+	fmt.Fprintf(r.pages[len(r.pages)-1], "0.5 w %.1f %.1f m %.1f %.1f l S\n",
+		pdfMargin, r.y, pdfPageWidth-pdfMargin, r.y)
+	r.y -= 2
+}
+
+func (r *pdfReport) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// object 1-4: catalog, pages, ฟอนต์ปกติและตัวหนา แล้วตามด้วย page/content ทีละคู่
+	kids := make([]string, len(r.pages))
+	for i := range r.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(r.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range r.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+i*2))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%EOF\n", len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune('?')
+		}
+	}
+	return b.String()
+}
+
+// formatBahtSatang เหมือน formatBaht แต่มีทศนิยม 2 ตำแหน่ง เช่น ส่วนแบ่งจากกลุ่ม
+func formatBahtSatang(v float64) string {
+	satang := int(math.Round(v * 100))
+	sign := ""
+	if satang < 0 {
+		sign, satang = "-", -satang
+	}
+	return fmt.Sprintf("%s%s.%02d", sign, formatBaht(satang/100), satang%100)
+}
+
+// formatBaht ใส่ comma คั่นหลักพัน เช่น 6000000 -> "6,000,000"
+func formatBaht(n int) string {
+	s := strconv.Itoa(n)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// secret ของชุดทดสอบใน RFC 6238 ภาคผนวก B ("12345678901234567890")
+var rfcTOTPSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeAtRFC6238(t *testing.T) {
+	// RFC ให้รหัส 8 หลัก รหัส 6 หลักคือ 6 หลักท้าย
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := totpCodeAt(rfcTOTPSecret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCodeAt(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCodeAt(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	code := func(s int64) string {
+		c, _ := totpCodeAt(rfcTOTPSecret, s)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(step), step, true},
+		{"previous step within skew", code(step - 1), step - 1, true},
+		{"next step within skew", code(step + 1), step + 1, true},
+		{"outside skew", code(step + 2), 0, false},
+		{"spaces are ignored", code(step)[:3] + " " + code(step)[3:], step, true},
+		{"wrong length", code(step)[:5], 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		gotStep, ok := verifyTOTP(rfcTOTPSecret, tt.code, now)
+		if ok != tt.wantOK || gotStep != tt.wantStep {
+			t.Errorf("%s: verifyTOTP(%q) = %d, %v; want %d, %v", tt.name, tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+		}
+	}
+}
+
+func TestVerifyTOTPInvalidSecret(t *testing.T) {
+	if _, ok := verifyTOTP("not base32!", "123456", time.Now()); ok {
+		t.Fatalf("verifyTOTP accepted an invalid secret")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generateTOTPSecret: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret decodes to %d bytes (%v), want 20", len(key), err)
+	}
+	if _, err := totpCodeAt(secret, 1); err != nil {
+		t.Fatalf("totpCodeAt with a generated secret: %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+// purgeLotteries ลบสลากถาวรพร้อมรูปหลักฐานทุกรูปในที่เก็บไฟล์
+// ถ้าลบรูปไม่สำเร็จจะเก็บสลากใบนั้นไว้ เพื่อไม่ให้รูปค้างอยู่โดยไม่มีใครอ้างถึง
+func purgeLotteries(c *gin.Context, filter bson.M) (int, error) {
+	cursor, err := getLotteryCollection().Find(context.Background(), filter)
+	if err != nil {
+		return 0, err
+	}
+	var lotteries []models.Lottery
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, l := range lotteries {
+		imagesDeleted := true
+		for _, key := range lotteryImageKeys(l) {
+			if err := config.Blobs.Delete(context.TODO(), key); err != nil {
+				log.Println("purge lottery image:", key, err)
+				imagesDeleted = false
+				break
+			}
+		}
+		if !imagesDeleted {
+			continue
+		}
+		if _, err := getLotteryCollection().DeleteOne(context.Background(), bson.M{"_id": l.ID}); err != nil {
+			return purged, err
+		}
+		before := l
+		recordLotteryEvent(c, models.LotteryEventPurge, &before, nil)
+		purged++
+	}
+	return purged, nil
+}
+
+// trashScope คือเงื่อนไขของสลากในถังขยะที่ผู้ใช้เห็นได้ รวมสลากของกลุ่มที่เป็นสมาชิกอยู่เหมือนรายการปกติ
+// ตอบ 500 และคืน false เมื่ออ่านกลุ่มไม่ได้
+func trashScope(c *gin.Context, uid primitive.ObjectID) (bson.M, bool) {
+	filter, err := lotteryScope(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get pools"})
+		return nil, false
+	}
+	filter["deleted_at"] = bson.M{"$ne": nil}
+	return filter, true
+}
+
+// ====================== Trash ======================
+func ListTrash(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	filter, ok := trashScope(c, uid)
+	if !ok {
+		return
+	}
+	cursor, err := getLotteryCollection().Find(context.Background(), filter,
+		options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get trash"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	lotteries := []models.Lottery{}
+	if err := cursor.All(context.Background(), &lotteries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse trash"})
+		return
+	}
+
+	presignLotteries(c.Request.Context(), lotteries)
+	items := make([]gin.H, len(lotteries))
+	for i, l := range lotteries {
+		items[i] = gin.H{"lottery": l, "purge_at": l.DeletedAt.Add(config.TrashRetention)}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"retention_days": int(config.TrashRetention.Hours() / 24),
+		"items":          items,
+	})
+}
+
+// RestoreLottery ย้ายสลากออกจากถังขยะ ถ้ามีใบที่งวดและเลขเดียวกันอยู่แล้วจะรวมจำนวนเข้าด้วยกัน
+func RestoreLottery(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	filter, ok := trashScope(c, uid)
+	if !ok {
+		return
+	}
+	filter["_id"] = objID
+
+	var lot models.Lottery
+	if err := getLotteryCollection().FindOne(context.Background(), filter).Decode(&lot); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found in trash"})
+		return
+	}
+
+	// ลองสองรอบ: ถ้าใบเดิมถูกลบหรือมีใบซ้ำถูกสร้างระหว่างนี้ รอบที่สองจะเลือกใหม่ว่ารวมหรือกู้คืน
+	for attempt := 0; attempt < 2; attempt++ {
+		var existing models.Lottery
+		err := getLotteryCollection().FindOne(context.Background(),
+			lotteryDuplicateFilter(lot.UserID, lot.PoolID, lot.Round, lot.Number),
+		).Decode(&existing)
+		if err == nil {
+			// รวมจำนวน รูป และการขึ้นเงินเข้ากับใบเดิม
+			after, err := mergeDuplicateLottery(c, existing, lot)
+			if err == mongo.ErrNoDocuments {
+				continue
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot restore lottery"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Restored and merged", "id": after.ID.Hex()})
+			return
+		} else if err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot restore lottery"})
+			return
+		}
+
+		result, err := getLotteryCollection().UpdateOne(context.Background(),
+			bson.M{"_id": lot.ID, "deleted_at": bson.M{"$ne": nil}},
+			bson.M{
+				"$unset": bson.M{"deleted_at": ""},
+				"$set":   bson.M{"updated_at": time.Now()},
+			},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot restore lottery"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			break
+		}
+		after := lot
+		after.DeletedAt = nil
+		after.UpdatedAt = time.Now()
+		recordLotteryEvent(c, models.LotteryEventRestore, &lot, &after)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Restored", "id": lot.ID.Hex()})
+		return
+	}
+
+	// ถูกกู้คืน รวม หรือลบถาวรไปพร้อมกันแล้ว
+	c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found in trash"})
+}
+
+func PurgeTrashedLottery(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lottery ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	filter, ok := trashScope(c, uid)
+	if !ok {
+		return
+	}
+	filter["_id"] = objID
+
+	purged, err := purgeLotteries(c, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot purge lottery"})
+		return
+	}
+	if purged == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lottery not found in trash"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deleted permanently"})
+}
+
+func EmptyTrash(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	filter, ok := trashScope(c, uid)
+	if !ok {
+		return
+	}
+
+	purged, err := purgeLotteries(c, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot empty trash"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "purged": purged})
+}
+
+// StartTrashPurgeJob ลบสลากที่อยู่ในถังขยะนานเกิน TRASH_RETENTION_DAYS เป็นระยะ
+func StartTrashPurgeJob(interval time.Duration) {
+	go func() {
+		for {
+			purged, err := purgeLotteries(nil, bson.M{
+				"deleted_at": bson.M{"$lte": time.Now().Add(-config.TrashRetention)},
+			})
+			if err != nil {
+				log.Println("purge trash:", err)
+			} else if purged > 0 {
+				log.Printf("purged %d trashed lotteries", purged)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"log"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	usernameMinLength = 3
+	usernameMaxLength = 32
+	passwordMinLength = 8
+	passwordMaxLength = 72 // bcrypt ใช้ได้สูงสุด 72 ไบต์
+	emailMaxLength    = 254
+
+	// ชื่อผู้ใช้ที่ขึ้นต้นด้วย prefix นี้สงวนไว้สำหรับบัญชีที่สร้างจาก BiometricLogin
+	reservedUsernamePrefix = "user_"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.]+$`)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var breachedPasswords = map[string]struct{}{}
+
+func init() {
+	loadPasswordList(strings.NewReader(commonPasswordsFile))
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Println("cannot open BREACHED_PASSWORDS_FILE:", err)
+			return
+		}
+		defer f.Close()
+		loadPasswordList(f)
+	}
+}
+
+func loadPasswordList(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breachedPasswords[strings.ToLower(line)] = struct{}{}
+	}
+}
+
+// RegisterRequest ข้อมูลที่ผู้ใช้ส่งมาตอนสมัครสมาชิก
+// แยกจาก models.User เพื่อไม่ให้ client กำหนด id, created_at หรือสถานะอื่นเองได้
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (r *RegisterRequest) Normalize() {
+	r.Username = strings.TrimSpace(r.Username)
+	r.Email = normalizeEmail(r.Email)
+}
+
+// Validate คืนข้อผิดพลาดรายฟิลด์ (ว่างเปล่าหากข้อมูลถูกต้อง)
+func (r *RegisterRequest) Validate() map[string]string {
+	errs := map[string]string{}
+	if msg := validateUsername(r.Username); msg != "" {
+		errs["username"] = msg
+	}
+	if msg := validateEmail(r.Email); msg != "" {
+		errs["email"] = msg
+	}
+	if msg := validatePassword(r.Password, r.Username, r.Email); msg != "" {
+		errs["password"] = msg
+	}
+	return errs
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// emailMatch ใช้ค้นอีเมลแบบไม่สนตัวพิมพ์ใหญ่เล็ก เพราะอีเมลที่บันทึกก่อนมี normalizeEmail อาจมีตัวพิมพ์ใหญ่
+func emailMatch(email string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(normalizeEmail(email)) + "$", Options: "i"}
+}
+
+func validateUsername(username string) string {
+	switch {
+	case username == "":
+		return "กรุณากรอกชื่อผู้ใช้"
+	case len(username) < usernameMinLength || len(username) > usernameMaxLength:
+		return "ชื่อผู้ใช้ต้องมีความยาว 3-32 ตัวอักษร"
+	case !usernamePattern.MatchString(username):
+		return "ชื่อผู้ใช้ใช้ได้เฉพาะ a-z, A-Z, 0-9, _ และ ."
+	case strings.HasPrefix(strings.ToLower(username), reservedUsernamePrefix):
+		return "ไม่สามารถใช้ชื่อผู้ใช้ที่ขึ้นต้นด้วย user_ ได้"
+	}
+	return ""
+}
+
+func validateEmail(email string) string {
+	if email == "" {
+		return "กรุณากรอกอีเมล"
+	}
+	if len(email) > emailMaxLength {
+		return "อีเมลยาวเกินไป"
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		return "รูปแบบอีเมลไม่ถูกต้อง"
+	}
+	return ""
+}
+
+// validatePassword ตรวจนโยบายรหัสผ่าน ใช้ร่วมกันทั้งตอนสมัคร รีเซ็ต และเปลี่ยนรหัสผ่าน
+func validatePassword(password, username, email string) string {
+	if len(password) < passwordMinLength {
+		return "รหัสผ่านต้องมีอย่างน้อย 8 ตัวอักษร"
+	}
+	if len(password) > passwordMaxLength {
+		return "รหัสผ่านต้องยาวไม่เกิน 72 ไบต์"
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return "รหัสผ่านต้องมีทั้งตัวอักษรและตัวเลข"
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return "รหัสผ่านต้องไม่มีชื่อผู้ใช้อยู่ในนั้น"
+	}
+	if local, _, ok := strings.Cut(email, "@"); ok && len(local) >= usernameMinLength && strings.Contains(lower, local) {
+		return "รหัสผ่านต้องไม่มีอีเมลอยู่ในนั้น"
+	}
+	if _, breached := breachedPasswords[lower]; breached {
+		return "รหัสผ่านนี้เคยรั่วไหลหรือคาดเดาง่าย กรุณาใช้รหัสผ่านอื่น"
+	}
+	return ""
+}
+
+// bindOptionalJSON เหมือน ShouldBindJSON แต่ยอมรับ body ว่างสำหรับ endpoint ที่ทุกฟิลด์ไม่บังคับ
+func bindOptionalJSON(c *gin.Context, obj interface{}) error {
+	if err := c.ShouldBindJSON(obj); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRegisterRequestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    RegisterRequest
+		fields []string
+	}{
+		{"valid", RegisterRequest{Username: "somchai", Email: "somchai@example.com", Password: "Lucky2568go"}, nil},
+		{"empty", RegisterRequest{}, []string{"username", "email", "password"}},
+		{"short username", RegisterRequest{Username: "ab", Email: "ab@example.com", Password: "Lucky2568go"}, []string{"username"}},
+		{"username characters", RegisterRequest{Username: "som chai", Email: "a@example.com", Password: "Lucky2568go"}, []string{"username"}},
+		{"reserved prefix", RegisterRequest{Username: "User_abc", Email: "a@example.com", Password: "Lucky2568go"}, []string{"username"}},
+		{"email without domain dot", RegisterRequest{Username: "somchai", Email: "a@localhost", Password: "Lucky2568go"}, []string{"email"}},
+		{"email with name", RegisterRequest{Username: "somchai", Email: "Somchai <a@example.com>", Password: "Lucky2568go"}, []string{"email"}},
+		{"short password", RegisterRequest{Username: "somchai", Email: "a@example.com", Password: "Ab1"}, []string{"password"}},
+		{"letters only", RegisterRequest{Username: "somchai", Email: "a@example.com", Password: "abcdefghij"}, []string{"password"}},
+		{"contains username", RegisterRequest{Username: "somchai", Email: "a@example.com", Password: "xSomChai99"}, []string{"password"}},
+		{"contains email", RegisterRequest{Username: "somchai", Email: "lucky@example.com", Password: "lucky12345"}, []string{"password"}},
+		{"common password", RegisterRequest{Username: "somchai", Email: "a@example.com", Password: "password1"}, []string{"password"}},
+	}
+	for _, tt := range tests {
+		tt.req.Normalize()
+		errs := tt.req.Validate()
+		if len(errs) != len(tt.fields) {
+			t.Errorf("%s: errors = %v, want fields %v", tt.name, errs, tt.fields)
+			continue
+		}
+		for _, f := range tt.fields {
+			if errs[f] == "" {
+				t.Errorf("%s: missing error for %s (got %v)", tt.name, f, errs)
+			}
+		}
+	}
+}
+
+func TestRegisterRequestNormalize(t *testing.T) {
+	req := RegisterRequest{Username: "  somchai ", Email: " Somchai@Example.COM "}
+	req.Normalize()
+	if req.Username != "somchai" || req.Email != "somchai@example.com" {
+		t.Fatalf("Normalize = %+v", req)
+	}
+}
+
+func TestEmailMatch(t *testing.T) {
+	m := emailMatch(" A.B+lotto@Example.com ")
+	re := regexp.MustCompile("(?" + m.Options + ")" + m.Pattern)
+	for email, want := range map[string]bool{
+		"a.b+lotto@example.com":   true,
+		"A.B+Lotto@EXAMPLE.COM":   true,
+		"axb+lotto@example.com":   false, // "." ต้องไม่ถูกตีความเป็น regex
+		"a.b+lotto@example.com.x": false,
+	} {
+		if got := re.MatchString(email); got != want {
+			t.Errorf("emailMatch matches %q = %v, want %v", email, got, want)
+		}
+	}
+}
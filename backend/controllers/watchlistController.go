@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"luckyPus/config"
+	"luckyPus/models"
+)
+
+const (
+	watchlistMax        = 50
+	watchLabelMaxLength = 50
+)
+
+func getWatchCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("watchlist")
+}
+
+func getWatchMatchCollection() *mongo.Collection {
+	return config.Client.Database("luckyPus").Collection("watch_matches")
+}
+
+// EnsureWatchlistIndexes สร้าง index ของ watchlist และกันบันทึกผลซ้ำในงวดเดียวกัน
+func EnsureWatchlistIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := getWatchCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println("create watchlist indexes:", err)
+	}
+
+	_, err = getWatchMatchCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "watch_id", Value: 1}, {Key: "draw_date", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "draw_date", Value: -1}}},
+	})
+	if err != nil {
+		log.Println("create watch match indexes:", err)
+	}
+
+	_, err = getNotificationCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		log.Println("create notification indexes:", err)
+	}
+}
+
+// watchKind คืนชนิดของเลขจากจำนวนหลัก หรือ "" เมื่อไม่ใช่ 2, 3 หรือ 6 หลัก
+func watchKind(number string) string {
+	if !digitsOnly.MatchString(number) {
+		return ""
+	}
+	switch len(number) {
+	case 6:
+		return models.WatchKindFull
+	case 3:
+		return models.WatchKindThree
+	case 2:
+		return models.WatchKindTwo
+	}
+	return ""
+}
+
+// matchWatch คืนชื่อรางวัลทั้งหมดที่เลขนี้ตรงในงวด
+func matchWatch(w models.Watch, draw models.Draw) []string {
+	var prizes []string
+	if w.Kind == models.WatchKindFull {
+		for _, prize := range draw.Prizes {
+			for _, n := range prize.Number {
+				if n == w.Number {
+					prizes = append(prizes, prize.Name)
+				}
+			}
+		}
+	}
+
+	for _, running := range draw.RunningNumbers {
+		for _, n := range running.Number {
+			var hit bool
+			switch running.ID {
+			case "runningNumberFrontThree":
+				hit = (w.Kind == models.WatchKindFull && w.Number[:3] == n) ||
+					(w.Kind == models.WatchKindThree && w.Number == n)
+			case "runningNumberBackThree":
+				hit = (w.Kind == models.WatchKindFull && w.Number[3:] == n) ||
+					(w.Kind == models.WatchKindThree && w.Number == n)
+			case "runningNumberBackTwo":
+				hit = (w.Kind == models.WatchKindFull && w.Number[4:] == n) ||
+					(w.Kind == models.WatchKindTwo && w.Number == n)
+			}
+			if hit {
+				prizes = append(prizes, running.Name)
+			}
+		}
+	}
+	return prizes
+}
+
+// watchEvalLocks ให้ evaluateWatchlists ของงวดเดียวกันทำทีละครั้ง
+// (saveDraw จาก API และจากผู้ดูแลอาจเกิดพร้อมกัน) key คือ unix ของวันออกรางวัล
+// นับจำนวนผู้ใช้ lock ไว้ และลบออกจาก map เมื่อไม่มีใครรอแล้ว map จึงไม่โตไปตามจำนวนงวด
+type watchEvalLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	watchEvalMu    sync.Mutex
+	watchEvalLocks = map[int64]*watchEvalLock{}
+)
+
+// lockWatchEval รอจนได้ lock ของงวด แล้วคืนฟังก์ชันสำหรับปล่อย lock
+func lockWatchEval(drawDate time.Time) func() {
+	key := drawDate.Unix()
+
+	watchEvalMu.Lock()
+	l := watchEvalLocks[key]
+	if l == nil {
+		l = &watchEvalLock{}
+		watchEvalLocks[key] = l
+	}
+	l.refs++
+	watchEvalMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		watchEvalMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(watchEvalLocks, key)
+		}
+		watchEvalMu.Unlock()
+	}
+}
+
+// evaluateWatchlists ตรวจเลขที่ทุกคนติดตามกับผลงวดที่เพิ่งบันทึก แล้วแจ้งเตือนเฉพาะผลที่ตรงครั้งแรก
+// ถ้าผู้ดูแลแก้ผลรางวัล ผลเดิมที่ไม่ตรงแล้วจะถูกลบออก แต่จะไม่ลบเมื่ออ่านรายการไม่ครบ
+func evaluateWatchlists(draw models.Draw) {
+	unlock := lockWatchEval(draw.DrawDate)
+	defer unlock()
+
+	// ใช้ผลที่บันทึกล่าสุด เพราะระหว่างรอ lock อาจมีการบันทึกผลของงวดนี้ใหม่แล้ว
+	if latest, found := findDrawByDate(draw.DrawDate); found {
+		draw = latest
+	}
+
+	ctx := context.Background()
+	cursor, err := getWatchCollection().Find(ctx, bson.M{})
+	if err != nil {
+		log.Println("evaluate watchlist:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	round := draw.Date
+	if round == "" {
+		round = draw.DrawDate.In(bangkok).Format("2006-01-02")
+	}
+
+	matched := []primitive.ObjectID{}
+	complete := true
+	users := map[primitive.ObjectID]*models.User{}
+	for cursor.Next(ctx) {
+		var w models.Watch
+		if err := cursor.Decode(&w); err != nil {
+			log.Println("evaluate watchlist:", err)
+			complete = false
+			continue
+		}
+		prizes := matchWatch(w, draw)
+		if len(prizes) == 0 {
+			continue
+		}
+		matched = append(matched, w.ID)
+
+		result, err := getWatchMatchCollection().UpdateOne(ctx,
+			bson.M{"watch_id": w.ID, "draw_date": draw.DrawDate},
+			bson.M{
+				"$set": bson.M{"prizes": prizes, "round": round},
+				"$setOnInsert": bson.M{
+					"user_id":    w.UserID,
+					"number":     w.Number,
+					"kind":       w.Kind,
+					"created_at": time.Now(),
+				},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Println("save watch match:", err)
+			continue
+		}
+		if result.UpsertedCount == 0 {
+			continue
+		}
+
+		user, ok := users[w.UserID]
+		if !ok {
+			var u models.User
+			if err := getUserCollection().FindOne(ctx, bson.M{"_id": w.UserID}).Decode(&u); err == nil && !u.Disabled {
+				user = &u
+			}
+			users[w.UserID] = user
+		}
+		if user == nil {
+			continue
+		}
+
+		title := fmt.Sprintf("เลข %s ที่คุณติดตามถูกรางวัล", w.Number)
+		body := fmt.Sprintf("งวด %s เลข %s ตรงกับ %s", round, w.Number, strings.Join(prizes, ", "))
+		if w.Label != "" {
+			body = fmt.Sprintf("งวด %s เลข %s (%s) ตรงกับ %s", round, w.Number, w.Label, strings.Join(prizes, ", "))
+		}
+		data := map[string]string{"watch_id": w.ID.Hex(), "number": w.Number, "round": round}
+		if err := notifyUser(*user, models.NotificationWatchMatch, title, body, data); err != nil {
+			log.Println("notify watch match:", err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Println("evaluate watchlist:", err)
+		complete = false
+	}
+	if !complete {
+		log.Println("evaluate watchlist: skip cleaning watch matches of", round, "because the watchlist was not read completely")
+		return
+	}
+
+	_, err = getWatchMatchCollection().DeleteMany(ctx, bson.M{
+		"draw_date": draw.DrawDate,
+		"watch_id":  bson.M{"$nin": matched},
+	})
+	if err != nil {
+		log.Println("clean watch matches:", err)
+	}
+}
+
+// ====================== Watchlist ======================
+func ListWatchlist(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	cursor, err := getWatchCollection().Find(context.Background(),
+		bson.M{"user_id": uid},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get watchlist"})
+		return
+	}
+	items := []models.Watch{}
+	if err := cursor.All(context.Background(), &items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse watchlist"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// AddWatch เพิ่มเลขที่ติดตาม รับเลข 6 หลัก 3 ตัว หรือ 2 ตัว
+func AddWatch(c *gin.Context) {
+	var input struct {
+		Number string `json:"number" binding:"required"`
+		Label  string `json:"label"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	number := normalizeLotteryDigits(input.Number)
+	kind := watchKind(number)
+	if kind == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid number", "fields": gin.H{"number": "number must be 6, 3 or 2 digits"}})
+		return
+	}
+	label := strings.TrimSpace(input.Label)
+	if utf8.RuneCountInString(label) > watchLabelMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid number", "fields": gin.H{"label": "label must be at most 50 characters"}})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	count, err := getWatchCollection().CountDocuments(context.Background(), bson.M{"user_id": uid})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot add number"})
+		return
+	}
+	if count >= watchlistMax {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d numbers in the watchlist", watchlistMax)})
+		return
+	}
+
+	w := models.Watch{
+		UserID:    uid,
+		Number:    number,
+		Kind:      kind,
+		Label:     label,
+		CreatedAt: time.Now(),
+	}
+	res, err := getWatchCollection().InsertOne(context.Background(), w)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Number is already in the watchlist"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot add number"})
+		return
+	}
+	w.ID = res.InsertedID.(primitive.ObjectID)
+	c.JSON(http.StatusOK, w)
+}
+
+func DeleteWatch(c *gin.Context) {
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid watch ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	result, err := getWatchCollection().DeleteOne(context.Background(), bson.M{"_id": objID, "user_id": uid})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot delete number"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Number not found in watchlist"})
+		return
+	}
+	_, _ = getWatchMatchCollection().DeleteMany(context.Background(), bson.M{"watch_id": objID})
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from watchlist"})
+}
+
+// ListWatchMatches คืนผลที่เลขที่ติดตามเคยถูกรางวัล งวดล่าสุดก่อน
+func ListWatchMatches(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	uid, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	cursor, err := getWatchMatchCollection().Find(context.Background(),
+		bson.M{"user_id": uid},
+		options.Find().SetSort(bson.D{{Key: "draw_date", Value: -1}, {Key: "number", Value: 1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot get matches"})
+		return
+	}
+	items := []models.WatchMatch{}
+	if err := cursor.All(context.Background(), &items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot parse matches"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
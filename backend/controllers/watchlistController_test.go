@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"luckyPus/models"
+)
+
+var testDraw = models.Draw{
+	Prizes: []models.DrawPrize{
+		{ID: "prizeFirst", Name: "รางวัลที่ 1", Number: []string{"123456"}},
+		{ID: "prizeSecond", Name: "รางวัลที่ 2", Number: []string{"111111", "222222"}},
+	},
+	RunningNumbers: []models.DrawPrize{
+		{ID: "runningNumberFrontThree", Name: "เลขหน้า 3 ตัว", Number: []string{"123", "999"}},
+		{ID: "runningNumberBackThree", Name: "เลขท้าย 3 ตัว", Number: []string{"456", "888"}},
+		{ID: "runningNumberBackTwo", Name: "เลขท้าย 2 ตัว", Number: []string{"56"}},
+	},
+}
+
+func TestMatchWatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		watch models.Watch
+		want  []string
+	}{
+		{
+			"first prize also matches running numbers",
+			models.Watch{Number: "123456", Kind: models.WatchKindFull},
+			[]string{"รางวัลที่ 1", "เลขหน้า 3 ตัว", "เลขท้าย 3 ตัว", "เลขท้าย 2 ตัว"},
+		},
+		{
+			"full number with only the last two digits",
+			models.Watch{Number: "000056", Kind: models.WatchKindFull},
+			[]string{"เลขท้าย 2 ตัว"},
+		},
+		{
+			"full number in a prize with several numbers",
+			models.Watch{Number: "222222", Kind: models.WatchKindFull},
+			[]string{"รางวัลที่ 2"},
+		},
+		{
+			"three digits front",
+			models.Watch{Number: "999", Kind: models.WatchKindThree},
+			[]string{"เลขหน้า 3 ตัว"},
+		},
+		{
+			"three digits back",
+			models.Watch{Number: "888", Kind: models.WatchKindThree},
+			[]string{"เลขท้าย 3 ตัว"},
+		},
+		{
+			"two digits",
+			models.Watch{Number: "56", Kind: models.WatchKindTwo},
+			[]string{"เลขท้าย 2 ตัว"},
+		},
+		{
+			"three digits do not match the full prize",
+			models.Watch{Number: "456", Kind: models.WatchKindThree},
+			[]string{"เลขท้าย 3 ตัว"},
+		},
+		{
+			"no match",
+			models.Watch{Number: "654321", Kind: models.WatchKindFull},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		if got := matchWatch(tt.watch, testDraw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matchWatch = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWatchKind(t *testing.T) {
+	tests := map[string]string{
+		"123456": models.WatchKindFull,
+		"123":    models.WatchKindThree,
+		"12":     models.WatchKindTwo,
+		"1":      "",
+		"1234":   "",
+		"12a":    "",
+	}
+	for number, want := range tests {
+		if got := watchKind(number); got != want {
+			t.Errorf("watchKind(%q) = %q, want %q", number, got, want)
+		}
+	}
+}
+
+func TestLockWatchEval(t *testing.T) {
+	draw := time.Date(2025, 10, 16, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	running, maxRunning := 0, 0
+	var mu sync.Mutex
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockWatchEval(draw)
+			defer unlock()
+
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning != 1 {
+		t.Errorf("%d evaluations of the same draw ran at once", maxRunning)
+	}
+	watchEvalMu.Lock()
+	defer watchEvalMu.Unlock()
+	if len(watchEvalLocks) != 0 {
+		t.Errorf("watchEvalLocks not pruned: %d entries", len(watchEvalLocks))
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"luckyPus/config"
+	"luckyPus/controllers"
+	"luckyPus/middleware"
 	"luckyPus/routes"
 
 	"github.com/gin-contrib/cors"
@@ -16,7 +18,11 @@ import (
 func main() {
 	config.LoadEnv()
 	config.ConnectDB()
-	config.LoadS3()
+	config.LoadBlobStore()
+	config.LoadMailer()
+	config.LoadOIDC()
+	middleware.Init()
+	controllers.Init()
 
 	gin.SetMode(gin.ReleaseMode)
 
@@ -28,14 +34,24 @@ func main() {
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:  allowOrigins,
-		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders: []string{"Content-Length"},
+		ExposeHeaders: []string{"Content-Length", "X-Next-Cursor", "X-Has-More"},
 		MaxAge:        12 * time.Hour,
 	}))
 
 	routes.SetupRoutes(router)
 
+	controllers.EnsureLotteryIndexes()
+	controllers.EnsureWatchlistIndexes()
+	controllers.NormalizeStoredEmails()
+	controllers.EnsureUserIndexes()
+	controllers.EnsureIdentityIndexes()
+	controllers.StartAccountPurgeJob(time.Hour)
+	controllers.StartTrashPurgeJob(time.Hour)
+	controllers.StartImageUploadCleanupJob(time.Hour)
+	controllers.StartImageProcessingJob(time.Minute)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
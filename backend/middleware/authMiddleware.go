@@ -1,21 +1,26 @@
 package middleware
 
 import (
+	"context"
 	"luckyPus/config"
+	"luckyPus/models"
 	"net/http"
 	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var jwtKey []byte
 
-func init() {
-	config.LoadEnv()
-	config.ConnectDB()
-
+// Init ตั้งค่า middleware ต้องเรียกหลัง config.LoadEnv และ config.ConnectDB
+func Init() {
 	jwtKey = []byte(config.JWTSecret)
+	loadLimiterStore()
 }
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -32,14 +37,67 @@ func AuthMiddleware() gin.HandlerFunc {
 			return jwtKey, nil
 		})
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("user_id", claims["user_id"].(string))
-		} else {
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
+		claims, ok := token.Claims.(jwt.MapClaims)
+		userID, hasUserID := claims["user_id"].(string)
+		// โทเค็นชนิดอื่น (เช่น MFA challenge) ใช้แทน access token ไม่ได้
+		if !ok || !token.Valid || !hasUserID || claims["typ"] != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		// บัญชีที่ถูกระงับใช้ access token เดิมต่อไม่ได้
+		// role อ่านจากฐานข้อมูลทุกครั้ง ไม่ใช้ค่าใน token ที่อาจออกก่อนถูกถอด role
+		user, found, err := tokenUserLoader(userID)
+		if err != nil {
+			// ตรวจสถานะบัญชีไม่ได้ ไม่ปล่อยผ่านด้วย role ว่างหรือสถานะเก่า
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+			c.Abort()
+			return
+		}
+		if !found || user.Disabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", userID)
+		c.Set("roles", user.EffectiveRoles())
+		if deviceID, ok := claims["device_id"].(string); ok {
+			c.Set("device_id", deviceID)
+		}
+
 		c.Next()
 	}
 }
+
+// tokenUserLoader แทนที่ได้ในเทสต์ที่ไม่มีฐานข้อมูล
+var tokenUserLoader = loadTokenUser
+
+// loadTokenUser อ่านสถานะระงับและ role ของเจ้าของ token
+// คืน found เป็น false เมื่อไม่พบบัญชี และคืน error เมื่ออ่านฐานข้อมูลไม่ได้
+func loadTokenUser(userID string) (models.User, bool, error) {
+	var user models.User
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return user, false, nil
+	}
+
+	err = config.Client.Database("luckyPus").Collection("users").FindOne(
+		context.Background(),
+		bson.M{"_id": uid},
+		options.FindOne().SetProjection(bson.M{"disabled": 1, "roles": 1}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, false, nil
+	} else if err != nil {
+		return user, false, err
+	}
+	return user, true, nil
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"luckyPus/models"
+)
+
+// useTokenUsers ตั้ง jwtKey และแทน tokenUserLoader ด้วยผู้ใช้ในหน่วยความจำระหว่างเทสต์
+// loadErr ที่ไม่ใช่ nil จำลองฐานข้อมูลที่อ่านไม่ได้
+func useTokenUsers(t *testing.T, users map[string]models.User, loadErr error) {
+	t.Helper()
+	prevKey, prevLoader := jwtKey, tokenUserLoader
+	jwtKey = []byte("test-jwt-secret")
+	tokenUserLoader = func(userID string) (models.User, bool, error) {
+		if loadErr != nil {
+			return models.User{}, false, loadErr
+		}
+		user, ok := users[userID]
+		return user, ok, nil
+	}
+	t.Cleanup(func() { jwtKey, tokenUserLoader = prevKey, prevLoader })
+}
+
+func signAccessToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// serveAdmin ส่งคำขอผ่าน AuthMiddleware และ RequireRole(admin) คืน status ที่ได้
+func serveAdmin(token string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin", AuthMiddleware(), RequireRole(models.RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	req := httptest.NewRequest("GET", "/admin", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAuthMiddlewareAccountState(t *testing.T) {
+	admin := primitive.NewObjectID().Hex()
+	member := primitive.NewObjectID().Hex()
+	disabled := primitive.NewObjectID().Hex()
+	missing := primitive.NewObjectID().Hex()
+	useTokenUsers(t, map[string]models.User{
+		admin:    {Roles: []string{models.RoleAdmin}},
+		member:   {},
+		disabled: {Roles: []string{models.RoleAdmin}, Disabled: true},
+	}, nil)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	cases := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{"admin", jwt.MapClaims{"user_id": admin, "exp": exp}, http.StatusOK},
+		// role ใน token ไม่มีผล อ่านจากฐานข้อมูลเสมอ
+		{"role claim ignored", jwt.MapClaims{"user_id": member, "roles": []string{models.RoleAdmin}, "exp": exp}, http.StatusForbidden},
+		{"disabled admin", jwt.MapClaims{"user_id": disabled, "exp": exp}, http.StatusForbidden},
+		{"deleted account", jwt.MapClaims{"user_id": missing, "exp": exp}, http.StatusForbidden},
+		{"mfa challenge", jwt.MapClaims{"user_id": admin, "typ": "mfa", "exp": exp}, http.StatusUnauthorized},
+		{"expired", jwt.MapClaims{"user_id": admin, "exp": time.Now().Add(-time.Minute).Unix()}, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if got := serveAdmin(signAccessToken(t, tc.claims)); got != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	if got := serveAdmin(""); got != http.StatusUnauthorized {
+		t.Errorf("missing header: status = %d", got)
+	}
+}
+
+func TestAuthMiddlewareDatabaseError(t *testing.T) {
+	useTokenUsers(t, nil, errors.New("connection refused"))
+	token := signAccessToken(t, jwt.MapClaims{
+		"user_id": primitive.NewObjectID().Hex(),
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	if got := serveAdmin(token); got != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", got, http.StatusServiceUnavailable)
+	}
+}
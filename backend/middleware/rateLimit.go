@@ -0,0 +1,353 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"luckyPus/config"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// จำนวนครั้งที่ล้มเหลวก่อนเริ่มล็อก และระยะเวลาล็อกครั้งแรก
+	// หลังจากนั้นระยะเวลาล็อกจะเพิ่มเป็นสองเท่าทุกครั้งที่ล้มเหลว
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+	lockoutBase             = 30 * time.Second
+	lockoutMax              = time.Hour
+	failureDecay            = 24 * time.Hour
+)
+
+// LimiterStore เก็บตัวนับของ rate limit และสถานะการล็อก
+// ใช้ memoryLimiterStore สำหรับเครื่องเดียว หรือ mongoLimiterStore
+// เมื่อรันหลาย instance เพื่อให้ทุกเครื่องเห็นตัวนับเดียวกัน
+type LimiterStore interface {
+	// Hit นับคำขอใน window ปัจจุบัน คืนจำนวนครั้งและเวลาที่ window สิ้นสุด
+	Hit(key string, window time.Duration) (int, time.Time, error)
+	// Fail นับความล้มเหลวต่อเนื่องของ key และคืนจำนวนครั้งล่าสุด
+	Fail(key string) (int, error)
+	Lock(key string, until time.Time) error
+	LockedUntil(key string) (time.Time, error)
+	Reset(key string) error
+}
+
+// Limiter ถูกสร้างใน loadLimiterStore ตอน Init ตาม RATE_LIMIT_STORE
+var Limiter LimiterStore
+
+func loadLimiterStore() {
+	switch os.Getenv("RATE_LIMIT_STORE") {
+	case "mongo":
+		collection := config.ConnectDB().Database("luckyPus").Collection("rate_limits")
+		// ให้ MongoDB ลบตัวนับที่ไม่ได้ใช้แล้วเอง ตาม expires_at ที่ตั้งทุกครั้งที่เขียน
+		_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			log.Println("rate limit index:", err)
+		}
+		Limiter = &mongoLimiterStore{collection: collection}
+		log.Println("Rate limiter store: mongo")
+	default:
+		Limiter = newMemoryLimiterStore()
+	}
+}
+
+// ====================== Middleware ======================
+
+// RateLimit จำกัดจำนวนคำขอต่อ IP สำหรับ scope หนึ่ง ๆ ภายในช่วงเวลา window
+// key ขึ้นต้นด้วย "rate:" แยกจากตัวนับความล้มเหลวของการเข้าสู่ระบบ
+func RateLimit(scope string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "rate:" + scope + ":ip:" + c.ClientIP()
+
+		count, resetAt, err := Limiter.Hit(key, window)
+		if err != nil {
+			log.Println("rate limit:", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(max(limit-count, 0)))
+
+		if count > limit {
+			abortTooManyRequests(c, time.Until(resetAt))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func abortTooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "มีการพยายามมากเกินไป กรุณาลองใหม่ภายหลัง",
+		"retry_after": seconds,
+	})
+	c.Abort()
+}
+
+// ====================== Login Lockout ======================
+
+func accountKey(username string) string { return "lockout:user:" + username }
+func ipKey(ip string) string            { return "lockout:ip:" + ip }
+
+// CheckLoginLockout ตอบกลับ 429 และคืนค่า false หากบัญชีหรือ IP ยังถูกล็อกอยู่
+// ใช้ key จากชื่อผู้ใช้ที่ส่งมา ไม่ว่าบัญชีจะมีอยู่จริงหรือไม่ เพื่อไม่ให้รั่วไหลข้อมูล
+func CheckLoginLockout(c *gin.Context, username string) bool {
+	var until time.Time
+	for _, key := range []string{accountKey(username), ipKey(c.ClientIP())} {
+		t, err := Limiter.LockedUntil(key)
+		if err != nil {
+			log.Println("rate limit:", err)
+			continue
+		}
+		if t.After(until) {
+			until = t
+		}
+	}
+
+	if until.After(time.Now()) {
+		abortTooManyRequests(c, time.Until(until))
+		return false
+	}
+	return true
+}
+
+// RecordLoginFailure นับความล้มเหลวของบัญชีและ IP แล้วล็อกแบบ exponential
+func RecordLoginFailure(c *gin.Context, username string) {
+	lockOnFailure(accountKey(username), accountFailureThreshold)
+	lockOnFailure(ipKey(c.ClientIP()), ipFailureThreshold)
+}
+
+// ResetLoginFailures ล้างตัวนับของบัญชีหลังเข้าสู่ระบบสำเร็จ
+// ตัวนับของ IP ไม่ถูกล้าง ไม่อย่างนั้นผู้โจมตีที่มีบัญชีจริงหนึ่งบัญชีจะเข้าสู่ระบบคั่นระหว่างการสุ่มรหัส
+// เพื่อล้างการล็อก IP ได้ ตัวนับของ IP จะหมดไปเองตาม failureDecay
+func ResetLoginFailures(c *gin.Context, username string) {
+	if err := Limiter.Reset(accountKey(username)); err != nil {
+		log.Println("rate limit:", err)
+	}
+}
+
+func lockOnFailure(key string, threshold int) {
+	failures, err := Limiter.Fail(key)
+	if err != nil {
+		log.Println("rate limit:", err)
+		return
+	}
+	if failures < threshold {
+		return
+	}
+
+	if err := Limiter.Lock(key, time.Now().Add(lockoutDuration(failures-threshold))); err != nil {
+		log.Println("rate limit:", err)
+	}
+}
+
+func lockoutDuration(n int) time.Duration {
+	if n > 16 {
+		return lockoutMax
+	}
+	d := lockoutBase << n
+	if d > lockoutMax {
+		return lockoutMax
+	}
+	return d
+}
+
+// ====================== In-memory Store ======================
+
+type limiterEntry struct {
+	Count       int       `bson:"count"`
+	WindowEnd   time.Time `bson:"window_end"`
+	Failures    int       `bson:"failures"`
+	LockedUntil time.Time `bson:"locked_until"`
+	FailedAt    time.Time `bson:"failed_at"` // ความล้มเหลวครั้งล่าสุด ใช้นับ failureDecay
+	UpdatedAt   time.Time `bson:"updated_at"`
+	ExpiresAt   time.Time `bson:"expires_at,omitempty"` // ใช้กับ TTL index ของ mongoLimiterStore
+}
+
+type memoryLimiterStore struct {
+	mu      sync.Mutex
+	entries map[string]*limiterEntry
+}
+
+func newMemoryLimiterStore() *memoryLimiterStore {
+	s := &memoryLimiterStore{entries: map[string]*limiterEntry{}}
+	go s.cleanup()
+	return s
+}
+
+func (s *memoryLimiterStore) entry(key string) *limiterEntry {
+	e, ok := s.entries[key]
+	if !ok {
+		e = &limiterEntry{}
+		s.entries[key] = e
+	}
+	return e
+}
+
+func (s *memoryLimiterStore) Hit(key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e := s.entry(key)
+	if !e.WindowEnd.After(now) {
+		e.Count = 0
+		e.WindowEnd = now.Add(window)
+	}
+	e.Count++
+	e.UpdatedAt = now
+	return e.Count, e.WindowEnd, nil
+}
+
+func (s *memoryLimiterStore) Fail(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e := s.entry(key)
+	if now.Sub(e.FailedAt) > failureDecay {
+		e.Failures = 0
+	}
+	e.Failures++
+	e.FailedAt = now
+	e.UpdatedAt = now
+	return e.Failures, nil
+}
+
+func (s *memoryLimiterStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entry(key).LockedUntil = until
+	return nil
+}
+
+func (s *memoryLimiterStore) LockedUntil(key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		return e.LockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *memoryLimiterStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *memoryLimiterStore) cleanup() {
+	for range time.Tick(10 * time.Minute) {
+		s.mu.Lock()
+		now := time.Now()
+		for key, e := range s.entries {
+			if now.Sub(e.UpdatedAt) > failureDecay && e.LockedUntil.Before(now) {
+				delete(s.entries, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ====================== Mongo Store ======================
+
+// limiterExpiry คือเวลาที่เอกสารของ key หมดความหมาย (ใช้กับ TTL index)
+// ความล้มเหลวนับต่อกันได้ภายใน failureDecay จึงต้องเก็บไว้อย่างน้อยเท่านั้น หรือจนกว่า window/การล็อกจะหมด
+func limiterExpiry(now time.Time, d time.Duration) time.Time {
+	return now.Add(max(d, failureDecay))
+}
+
+type mongoLimiterStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoLimiterStore) Hit(key string, window time.Duration) (int, time.Time, error) {
+	now := time.Now()
+	inWindow := bson.M{"$gt": bson.A{"$window_end", now}}
+
+	// ใช้ pipeline update เพื่อเริ่ม window ใหม่และนับในคำสั่งเดียวแบบ atomic
+	update := bson.A{bson.M{"$set": bson.M{
+		"count": bson.M{"$cond": bson.A{
+			inWindow,
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$count", 0}}, 1}},
+			1,
+		}},
+		"window_end": bson.M{"$cond": bson.A{inWindow, "$window_end", now.Add(window)}},
+		"updated_at": now,
+		"expires_at": limiterExpiry(now, window),
+	}}}
+
+	var e limiterEntry
+	err := s.collection.FindOneAndUpdate(context.Background(), bson.M{"_id": key}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&e)
+	return e.Count, e.WindowEnd, err
+}
+
+func (s *mongoLimiterStore) Fail(key string) (int, error) {
+	now := time.Now()
+	recent := bson.M{"$gt": bson.A{"$failed_at", now.Add(-failureDecay)}}
+
+	update := bson.A{bson.M{"$set": bson.M{
+		"failures": bson.M{"$cond": bson.A{
+			recent,
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}},
+			1,
+		}},
+		"failed_at":  now,
+		"updated_at": now,
+		"expires_at": limiterExpiry(now, 0),
+	}}}
+
+	var e limiterEntry
+	err := s.collection.FindOneAndUpdate(context.Background(), bson.M{"_id": key}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&e)
+	return e.Failures, err
+}
+
+func (s *mongoLimiterStore) Lock(key string, until time.Time) error {
+	_, err := s.collection.UpdateOne(context.Background(),
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"locked_until": until, "updated_at": time.Now(), "expires_at": limiterExpiry(time.Now(), time.Until(until))}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *mongoLimiterStore) LockedUntil(key string) (time.Time, error) {
+	var e limiterEntry
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": key}).Decode(&e)
+	if err == mongo.ErrNoDocuments {
+		return time.Time{}, nil
+	}
+	return e.LockedUntil, err
+}
+
+func (s *mongoLimiterStore) Reset(key string) error {
+	_, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": key})
+	return err
+}
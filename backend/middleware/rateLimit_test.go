@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useMemoryLimiter ตั้ง Limiter เป็น memoryLimiterStore ใหม่ระหว่างเทสต์
+func useMemoryLimiter(t *testing.T) *memoryLimiterStore {
+	t.Helper()
+	prev := Limiter
+	store := newMemoryLimiterStore()
+	Limiter = store
+	t.Cleanup(func() { Limiter = prev })
+	return store
+}
+
+func newLoginContext(ip string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/auth/login", nil)
+	c.Request.RemoteAddr = ip + ":1234"
+	return c, w
+}
+
+func TestMemoryLimiterHitWindow(t *testing.T) {
+	store := useMemoryLimiter(t)
+	for i := 1; i <= 3; i++ {
+		count, _, _ := store.Hit("k", time.Minute)
+		if count != i {
+			t.Fatalf("Hit #%d = %d", i, count)
+		}
+	}
+	// window ที่หมดแล้วเริ่มนับใหม่
+	store.entries["k"].WindowEnd = time.Now().Add(-time.Second)
+	if count, _, _ := store.Hit("k", time.Minute); count != 1 {
+		t.Fatalf("Hit after the window = %d, want 1", count)
+	}
+}
+
+func TestMemoryLimiterFailureDecay(t *testing.T) {
+	store := useMemoryLimiter(t)
+	store.Fail("k")
+	store.Fail("k")
+	store.entries["k"].FailedAt = time.Now().Add(-failureDecay - time.Minute)
+	if n, _ := store.Fail("k"); n != 1 {
+		t.Fatalf("Fail after failureDecay = %d, want 1", n)
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	tests := map[int]time.Duration{
+		0:  lockoutBase,
+		1:  2 * lockoutBase,
+		3:  8 * lockoutBase,
+		10: lockoutMax,
+		64: lockoutMax,
+	}
+	for n, want := range tests {
+		if got := lockoutDuration(n); got != want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestLoginLockoutAccount(t *testing.T) {
+	useMemoryLimiter(t)
+
+	for i := 0; i < accountFailureThreshold; i++ {
+		c, _ := newLoginContext("10.0.0.1")
+		if !CheckLoginLockout(c, "alice") {
+			t.Fatalf("locked after %d failures", i)
+		}
+		RecordLoginFailure(c, "alice")
+	}
+
+	// บัญชีถูกล็อกไม่ว่าจะมาจาก IP ไหน
+	c, w := newLoginContext("10.0.0.2")
+	if CheckLoginLockout(c, "alice") {
+		t.Fatalf("account is not locked after %d failures", accountFailureThreshold)
+	}
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("response = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// บัญชีอื่นจาก IP เดิมยังเข้าได้
+	c, _ = newLoginContext("10.0.0.1")
+	if !CheckLoginLockout(c, "bob") {
+		t.Fatalf("another account is locked")
+	}
+}
+
+func TestLoginLockoutIPSurvivesSuccessfulLogin(t *testing.T) {
+	useMemoryLimiter(t)
+	const ip = "10.0.0.3"
+
+	// ผู้โจมตีสุ่มรหัสหลายบัญชีจาก IP เดียว และเข้าบัญชีของตัวเองสำเร็จคั่นไว้
+	for i := 0; i < ipFailureThreshold; i++ {
+		c, _ := newLoginContext(ip)
+		RecordLoginFailure(c, "victim"+string(rune('a'+i)))
+		if i == ipFailureThreshold/2 {
+			ResetLoginFailures(c, "attacker")
+		}
+	}
+
+	c, w := newLoginContext(ip)
+	if CheckLoginLockout(c, "attacker") || w.Code != http.StatusTooManyRequests {
+		t.Fatalf("IP is not locked after %d failures", ipFailureThreshold)
+	}
+}
+
+func TestResetLoginFailuresClearsAccount(t *testing.T) {
+	useMemoryLimiter(t)
+	c, _ := newLoginContext("10.0.0.4")
+	for i := 0; i < accountFailureThreshold-1; i++ {
+		RecordLoginFailure(c, "alice")
+	}
+	ResetLoginFailures(c, "alice")
+	RecordLoginFailure(c, "alice")
+
+	c, _ = newLoginContext("10.0.0.4")
+	if !CheckLoginLockout(c, "alice") {
+		t.Fatalf("account locked although failures were reset")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	useMemoryLimiter(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", RateLimit("login", 2, time.Minute), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.RemoteAddr = "10.0.0.5:1234"
+		router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != 200 || codes[1] != 200 || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("codes = %v, want [200 200 429]", codes)
+	}
+
+	// IP อื่นมีตัวนับของตัวเอง
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.RemoteAddr = "10.0.0.6:1234"
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("other IP = %d, want 200", w.Code)
+	}
+}
+
+func TestLimiterExpiry(t *testing.T) {
+	now := time.Now()
+	if got := limiterExpiry(now, time.Minute); !got.Equal(now.Add(failureDecay)) {
+		t.Errorf("short window expires at %v, want after failureDecay", got)
+	}
+	if got := limiterExpiry(now, 2*failureDecay); !got.Equal(now.Add(2 * failureDecay)) {
+		t.Errorf("long window expires at %v", got)
+	}
+}
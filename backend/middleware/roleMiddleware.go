@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole ต้องใช้ต่อจาก AuthMiddleware และอนุญาตเฉพาะผู้ใช้ที่มี role ใด role หนึ่งที่กำหนด
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, _ := c.Get("roles")
+		userRoles, _ := granted.([]string)
+
+		for _, have := range userRoles {
+			for _, want := range roles {
+				if have == want {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Abort()
+	}
+}
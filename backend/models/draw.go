@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DrawPrize โครงสร้างเดียวกับ prizes/runningNumbers ของ lotto API
+type DrawPrize struct {
+	ID     string   `bson:"id" json:"id"`
+	Name   string   `bson:"name" json:"name"`
+	Reward int      `bson:"reward" json:"reward"`
+	Number []string `bson:"number" json:"number"`
+}
+
+type Draw struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DrawDate       time.Time          `bson:"draw_date" json:"draw_date"`
+	Date           string             `bson:"date" json:"date"` // วันที่ตามต้นทาง เช่น "16 ตุลาคม 2568"
+	Prizes         []DrawPrize        `bson:"prizes" json:"prizes"`
+	RunningNumbers []DrawPrize        `bson:"running_numbers" json:"running_numbers"`
+	Source         string             `bson:"source" json:"source"` // "api", "admin"
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity บัญชีภายนอก (Apple, LINE ฯลฯ) ที่ผูกกับผู้ใช้ ระบุด้วย provider + subject
+type Identity struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Provider      string             `bson:"provider" json:"provider"`
+	Subject       string             `bson:"subject" json:"subject"`
+	Email         string             `bson:"email,omitempty" json:"email,omitempty"`
+	EmailVerified bool               `bson:"email_verified" json:"email_verified"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	LastLoginAt   time.Time          `bson:"last_login_at,omitempty" json:"last_login_at,omitempty"`
+}
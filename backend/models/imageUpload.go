@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ImageUploadPending    = "pending"    // ออกลิงก์แล้ว รอ client อัปโหลดและแจ้ง complete
+	ImageUploadProcessing = "processing" // แจ้ง complete แล้ว รอ worker แปลงรูป
+	ImageUploadDone       = "done"
+	ImageUploadFailed     = "failed"
+)
+
+// ImageUpload คือรูปที่ออกลิงก์อัปโหลดตรงขึ้นที่เก็บไฟล์ ติดตามสถานะจนกว่า worker จะแปลงและเพิ่มเข้าสลาก
+// ID จะกลายเป็น id ของ LotteryImage เมื่อแปลงเสร็จ
+type ImageUpload struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	DeviceID    string             `bson:"device_id,omitempty" json:"-"`
+	LotteryID   primitive.ObjectID `bson:"lottery_id" json:"lottery_id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Key         string             `bson:"key" json:"key"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Size        int64              `bson:"size" json:"size"`
+	Status      string             `bson:"status,omitempty" json:"status"` // ว่างคือ pending (รายการก่อนมี status)
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	Attempts    int                `bson:"attempts,omitempty" json:"-"`
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expires_at"` // ลิงก์อัปโหลดหมดอายุ
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	ClaimedAt   *time.Time         `bson:"claimed_at,omitempty" json:"-"` // worker ที่กำลังแปลงรูปนี้รับงานไปเมื่อใด
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
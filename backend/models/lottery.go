@@ -6,14 +6,73 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// สถานะการขึ้นเงินรางวัล expired คำนวณจากวันออกรางวัล ไม่ได้บันทึกลงฐานข้อมูล
+const (
+	ClaimUnclaimed = "unclaimed"
+	ClaimClaimed   = "claimed"
+	ClaimExpired   = "expired"
+)
+
+// ประเภทของรูปหลักฐาน
+const (
+	LotteryImageFront   = "front"
+	LotteryImageBack    = "back"
+	LotteryImageReceipt = "receipt"
+)
+
+// LotteryImage คือรูปหลักฐานหนึ่งรูปของสลาก ลำดับใน Lottery.Images คือลำดับที่แสดง
+type LotteryImage struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Key         string             `bson:"key" json:"key"` // object key ใน S3
+	URL         string             `bson:"url" json:"url"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Size        int64              `bson:"size" json:"size"`
+	Width       int                `bson:"width,omitempty" json:"width,omitempty"`
+	Height      int                `bson:"height,omitempty" json:"height,omitempty"`
+	SHA256      string             `bson:"sha256,omitempty" json:"sha256,omitempty"`
+	UploadedAt  time.Time          `bson:"uploaded_at" json:"uploaded_at"`
+
+	Thumbnails []LotteryImageThumbnail `bson:"thumbnails,omitempty" json:"thumbnails,omitempty"`
+}
+
+// LotteryImageThumbnail คือรูปย่อที่สร้างตอนอัปโหลด ใช้แสดงในหน้ารายการ
+type LotteryImageThumbnail struct {
+	Size   string `bson:"size" json:"size"` // small หรือ medium
+	Key    string `bson:"key" json:"key"`
+	URL    string `bson:"url" json:"url"`
+	Width  int    `bson:"width" json:"width"`
+	Height int    `bson:"height" json:"height"`
+}
+
 type Lottery struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Round     string             `bson:"round" json:"round"` // เช่น "1/10/2025"
-	Number    string             `bson:"number" json:"number"`
-	Quantity  int                `bson:"quantity" json:"quantity"`
-	Status    string             `bson:"status" json:"status"` // "ยังไม่ตรวจสอบ", "ถูกรางวัลที่ ....", "ไม่ถูกรางวัล"
-	ImageURL  string             `bson:"image_url,omitempty" json:"image_url,omitempty"`
-	UpdatedAt time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Round    string             `bson:"round" json:"round"` // เช่น "1/10/2025"
+	Number   string             `bson:"number" json:"number"`
+	Quantity int                `bson:"quantity" json:"quantity"`
+	Status   string             `bson:"status" json:"status"` // "ยังไม่ตรวจสอบ", "ถูกรางวัลที่ ....", "ไม่ถูกรางวัล"
+	ImageURL string             `bson:"image_url,omitempty" json:"image_url,omitempty"`
+
+	// รูปหลักฐานทั้งหมด ImageURL คือ URL ของรูปแรกเพื่อให้ client เดิมยังแสดงรูปปกได้
+	Images []LotteryImage `bson:"images,omitempty" json:"images,omitempty"`
+
+	// สลากของกลุ่มซื้อร่วม UserID คือสมาชิกที่เป็นผู้บันทึก
+	PoolID *primitive.ObjectID `bson:"pool_id,omitempty" json:"pool_id,omitempty"`
+
+	// ข้อมูลประกอบที่ผู้ใช้กรอกเอง
+	Note             string     `bson:"note,omitempty" json:"note,omitempty"`
+	Tags             []string   `bson:"tags,omitempty" json:"tags,omitempty"` // เช่น "วันเกิด", "office pool"
+	PurchaseLocation string     `bson:"purchase_location,omitempty" json:"purchase_location,omitempty"`
+	Vendor           string     `bson:"vendor,omitempty" json:"vendor,omitempty"`
+	PurchasedAt      *time.Time `bson:"purchased_at,omitempty" json:"purchased_at,omitempty"`
+
+	// การขึ้นเงินรางวัล มีค่าเมื่อผู้ใช้บันทึกว่าขึ้นเงินแล้ว
+	ClaimStatus   string     `bson:"claim_status,omitempty" json:"claim_status,omitempty"`
+	ClaimedAt     *time.Time `bson:"claimed_at,omitempty" json:"claimed_at,omitempty"`
+	ClaimedAmount *float64   `bson:"claimed_amount,omitempty" json:"claimed_amount,omitempty"` // ยอดที่ได้รับจริง (บาท)
+
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // อยู่ในถังขยะเมื่อมีค่า
+	UpdatedAt time.Time  `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
 }
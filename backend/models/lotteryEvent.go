@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	LotteryEventCreate      = "create"
+	LotteryEventUpdate      = "update"
+	LotteryEventMerge       = "merge" // บวกจำนวนเข้ากับใบเดิมที่งวดและเลขตรงกัน
+	LotteryEventCheck       = "check"
+	LotteryEventClaim       = "claim"
+	LotteryEventImageUpload = "image_upload"
+	LotteryEventImageDelete = "image_delete"
+	LotteryEventDelete      = "delete"
+	LotteryEventRestore     = "restore"
+	LotteryEventPurge       = "purge"
+)
+
+// LotteryEvent คือประวัติการเปลี่ยนแปลงของสลากหนึ่งใบ บันทึกต่อท้ายอย่างเดียว ไม่แก้ไขย้อนหลัง
+type LotteryEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	LotteryID primitive.ObjectID `bson:"lottery_id" json:"lottery_id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Type      string             `bson:"type" json:"type"`
+	Before    *Lottery           `bson:"before,omitempty" json:"before,omitempty"`
+	After     *Lottery           `bson:"after,omitempty" json:"after,omitempty"`
+	ActorID   string             `bson:"actor_id,omitempty" json:"actor_id,omitempty"` // ว่างเมื่อระบบเป็นผู้ทำ เช่น ตรวจผลอัตโนมัติ
+	DeviceID  string             `bson:"device_id,omitempty" json:"device_id,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
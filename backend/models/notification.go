@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	NotificationWatchMatch = "watch_match"
+)
+
+// Notification คือการแจ้งเตือนในแอป ส่งทางอีเมลด้วยถ้าผู้ใช้เปิดไว้
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Type      string             `bson:"type" json:"type"`
+	Title     string             `bson:"title" json:"title"`
+	Body      string             `bson:"body" json:"body"`
+	Data      map[string]string  `bson:"data,omitempty" json:"data,omitempty"`
+	ReadAt    *time.Time         `bson:"read_at,omitempty" json:"read_at,omitempty"`
+	EmailedAt *time.Time         `bson:"emailed_at,omitempty" json:"emailed_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
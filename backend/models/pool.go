@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PoolMemberInvited = "invited"
+	PoolMemberActive  = "active"
+)
+
+type PoolMember struct {
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Username  string             `bson:"username" json:"username"`
+	Share     float64            `bson:"share" json:"share"` // เปอร์เซ็นต์ ใช้สัดส่วนเทียบกับผลรวมของสมาชิกที่ active
+	Status    string             `bson:"status" json:"status"`
+	InvitedBy primitive.ObjectID `bson:"invited_by,omitempty" json:"invited_by,omitempty"`
+	InvitedAt time.Time          `bson:"invited_at" json:"invited_at"`
+	JoinedAt  *time.Time         `bson:"joined_at,omitempty" json:"joined_at,omitempty"`
+}
+
+// Pool คือกลุ่มที่ซื้อสลากร่วมกัน สลากที่มี pool_id เป็นของทุกคนในกลุ่มตามสัดส่วน
+type Pool struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`
+	Members   []PoolMember       `bson:"members" json:"members"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ActiveShare คืนสัดส่วน (0-1) ของผู้ใช้เทียบกับผลรวม share ของสมาชิกที่ active
+func (p Pool) ActiveShare(userID primitive.ObjectID) float64 {
+	total, mine := 0.0, 0.0
+	for _, m := range p.Members {
+		if m.Status != PoolMemberActive {
+			continue
+		}
+		total += m.Share
+		if m.UserID == userID {
+			mine = m.Share
+		}
+	}
+	if total <= 0 {
+		return 0
+	}
+	return mine / total
+}
+
+func (p Pool) Member(userID primitive.ObjectID) (PoolMember, bool) {
+	for _, m := range p.Members {
+		if m.UserID == userID {
+			return m, true
+		}
+	}
+	return PoolMember{}, false
+}
@@ -1,11 +1,56 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+type NotificationPreferences struct {
+	DrawResults bool `bson:"draw_results" json:"draw_results"` // แจ้งผลตรวจสลากเมื่อออกรางวัล
+	Email       bool `bson:"email" json:"email"`
+	Push        bool `bson:"push" json:"push"`
+}
 
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Username  string             `bson:"username" json:"username"`
-	Password  string             `bson:"password" json:"password"`
-	Email     string             `bson:"email" json:"email"`
-	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username        string             `bson:"username" json:"username"`
+	Password        string             `bson:"password" json:"-"`
+	Email           string             `bson:"email" json:"email"`
+	EmailVerified   bool               `bson:"email_verified" json:"email_verified"`
+	EmailVerifiedAt primitive.DateTime `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+	Anonymous       bool               `bson:"anonymous" json:"anonymous"` // บัญชีที่สร้างอัตโนมัติจาก BiometricLogin
+	Roles           []string           `bson:"roles,omitempty" json:"roles"`
+	Disabled        bool               `bson:"disabled" json:"disabled"`
+	DeleteAfter     *time.Time         `bson:"delete_after,omitempty" json:"delete_after,omitempty"` // กำหนดลบบัญชีเมื่อพ้นระยะผ่อนผัน
+	CreatedAt       primitive.DateTime `bson:"created_at" json:"created_at"`
+
+	// Profile
+	DisplayName   string                  `bson:"display_name,omitempty" json:"display_name"`
+	Language      string                  `bson:"language,omitempty" json:"language"`
+	Timezone      string                  `bson:"timezone,omitempty" json:"timezone"`
+	Notifications NotificationPreferences `bson:"notifications" json:"notifications"`
+
+	// TOTP two-factor authentication
+	MFAEnabled        bool     `bson:"mfa_enabled" json:"mfa_enabled"`
+	TOTPSecret        string   `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPendingSecret string   `bson:"totp_pending_secret,omitempty" json:"-"`
+	TOTPLastStep      int64    `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes     []string `bson:"recovery_codes,omitempty" json:"-"` // sha256 ของ recovery code
+}
+
+// EffectiveRoles คืน role ของผู้ใช้ โดยทุกบัญชีมี role "user" เสมอ
+func (u User) EffectiveRoles() []string {
+	roles := []string{RoleUser}
+	for _, r := range u.Roles {
+		if r != RoleUser {
+			roles = append(roles, r)
+		}
+	}
+	return roles
 }
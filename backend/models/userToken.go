@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Purpose   string             `bson:"purpose" json:"purpose"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ชนิดของเลขที่ติดตาม กำหนดจากจำนวนหลัก
+const (
+	WatchKindFull  = "full"  // เลข 6 หลัก ตรวจกับทุกรางวัลเหมือนสลากจริง
+	WatchKindThree = "three" // เลข 3 ตัว ตรวจกับเลขหน้า/ท้าย 3 ตัว
+	WatchKindTwo   = "two"   // เลข 2 ตัว ตรวจกับเลขท้าย 2 ตัว
+)
+
+// Watch คือเลขที่ผู้ใช้ติดตามโดยไม่ได้ซื้อ เก็บแยกจาก lotteries
+type Watch struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Number    string             `bson:"number" json:"number"`
+	Kind      string             `bson:"kind" json:"kind"`
+	Label     string             `bson:"label,omitempty" json:"label,omitempty"` // เช่น "ทะเบียนรถ"
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// WatchMatch คือผลที่เลขที่ติดตามตรงกับรางวัลในงวดหนึ่ง หนึ่งเอกสารต่อเลขต่องวด
+type WatchMatch struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WatchID   primitive.ObjectID `bson:"watch_id" json:"watch_id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Number    string             `bson:"number" json:"number"`
+	Kind      string             `bson:"kind" json:"kind"`
+	DrawDate  time.Time          `bson:"draw_date" json:"draw_date"`
+	Round     string             `bson:"round" json:"round"`
+	Prizes    []string           `bson:"prizes" json:"prizes"` // ชื่อรางวัลที่ตรง
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
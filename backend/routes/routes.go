@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"time"
+
 	"luckyPus/controllers"
 	"luckyPus/middleware"
+	"luckyPus/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,11 +13,63 @@ import (
 func SetupRoutes(router *gin.Engine) {
 	auth := router.Group("/auth")
 	{
-		auth.POST("/refresh", controllers.RefreshToken)
-		auth.POST("/device/register", controllers.RegisterDevice)
-		auth.POST("/device/revoke", controllers.RevokeDevice)
-		auth.POST("/register", controllers.Register)
-		auth.POST("/login", controllers.Login)
+		auth.POST("/refresh", middleware.RateLimit("refresh", 30, time.Minute), controllers.RefreshToken)
+		auth.POST("/device/register", middleware.AuthMiddleware(), controllers.RegisterDevice)
+		auth.POST("/device/revoke", middleware.AuthMiddleware(), controllers.RevokeDevice)
+		auth.POST("/register", middleware.RateLimit("register", 5, 10*time.Minute), controllers.Register)
+		auth.POST("/login", middleware.RateLimit("login", 20, time.Minute), controllers.Login)
+		auth.POST("/login/mfa", middleware.RateLimit("login", 20, time.Minute), controllers.LoginMFA)
+		auth.POST("/oauth/nonce", middleware.RateLimit("nonce", 30, time.Minute), controllers.IssueOAuthNonce)
+		auth.POST("/oauth/:provider", middleware.RateLimit("login", 20, time.Minute), controllers.OAuthLogin)
+		auth.POST("/email/verify", controllers.VerifyEmail)
+		auth.GET("/email/verify", controllers.VerifyEmail)
+		auth.POST("/email/resend", middleware.RateLimit("email", 5, 10*time.Minute), controllers.ResendVerificationEmail)
+		auth.POST("/password/forgot", middleware.RateLimit("email", 5, 10*time.Minute), controllers.ForgotPassword)
+		auth.POST("/password/reset", middleware.RateLimit("reset", 10, 10*time.Minute), controllers.ResetPassword)
+	}
+
+	mfa := router.Group("/auth/mfa")
+	mfa.Use(middleware.AuthMiddleware())
+	{
+		mfa.POST("/enroll", controllers.EnrollMFA)
+		mfa.POST("/enable", controllers.EnableMFA)
+		mfa.POST("/disable", controllers.DisableMFA)
+		mfa.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
+	}
+
+	me := router.Group("/me")
+	me.Use(middleware.AuthMiddleware())
+	{
+		me.GET("", controllers.GetMe)
+		me.PATCH("", controllers.UpdateMe)
+		me.DELETE("", controllers.DeleteMe)
+		me.POST("/delete/cancel", controllers.CancelDeleteMe)
+		me.GET("/export", controllers.ExportMyData)
+		me.POST("/password", controllers.ChangePassword)
+		me.POST("/upgrade", controllers.UpgradeAccount)
+		me.GET("/identities", controllers.ListIdentities)
+		me.POST("/identities/:provider", controllers.LinkIdentity)
+		me.DELETE("/identities/:provider", controllers.UnlinkIdentity)
+		me.GET("/notifications", controllers.ListNotifications)
+		me.POST("/notifications/read", controllers.MarkAllNotificationsRead)
+		me.POST("/notifications/:id/read", controllers.MarkNotificationRead)
+	}
+
+	admin := router.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", controllers.AdminListUsers)
+		admin.GET("/users/:id", controllers.AdminGetUser)
+		admin.POST("/users/:id/disable", controllers.AdminDisableUser)
+		admin.POST("/users/:id/enable", controllers.AdminEnableUser)
+		admin.PUT("/users/:id/roles", controllers.AdminSetUserRoles)
+		admin.GET("/draws", controllers.AdminListDraws)
+		admin.PUT("/draws", controllers.AdminSaveDraw)
+		admin.POST("/recheck", controllers.AdminRecheck)
+		admin.GET("/lotteries/invalid-numbers", controllers.AdminInvalidNumbers)
+		admin.POST("/lotteries/invalid-numbers/fix", controllers.AdminFixInvalidNumbers)
+		admin.POST("/images/make-private", controllers.AdminMakeImagesPrivate)
+		admin.GET("/images/make-private", controllers.AdminImagesPrivateStatus)
 	}
 
 	lottery := router.Group("/lottery")
@@ -22,7 +77,25 @@ func SetupRoutes(router *gin.Engine) {
 	{
 		lottery.POST("/", controllers.CreateLottery)
 		lottery.GET("/", controllers.GetLotteries)
+		lottery.POST("/bulk", controllers.BulkCreateLottery)
+		lottery.POST("/import", controllers.ImportLottery)
+		lottery.GET("/export", controllers.ExportLotteries)
+		lottery.GET("/trash", controllers.ListTrash)
+		lottery.DELETE("/trash", controllers.EmptyTrash)
+		lottery.POST("/trash/:id/restore", controllers.RestoreLottery)
+		lottery.DELETE("/trash/:id", controllers.PurgeTrashedLottery)
+		lottery.GET("/claims", controllers.ListClaims)
 		lottery.PUT("/:id", controllers.UpdateLottery)
+		lottery.PUT("/:id/claim", controllers.ClaimLottery)
+		lottery.DELETE("/:id/claim", controllers.UnclaimLottery)
+		lottery.GET("/:id/history", controllers.GetLotteryHistory)
+		lottery.GET("/:id/image", controllers.GetLotteryImage)
+		lottery.GET("/:id/images", controllers.ListLotteryImages)
+		lottery.POST("/:id/images/upload-url", controllers.CreateImageUploadURL)
+		lottery.POST("/:id/images/complete", controllers.CompleteImageUpload)
+		lottery.GET("/:id/images/uploads/:upload_id", controllers.GetImageUpload)
+		lottery.PUT("/:id/images/order", controllers.ReorderLotteryImages)
+		lottery.DELETE("/:id/images/:image_id", controllers.DeleteLotteryImageByID)
 		lottery.DELETE("/:id", controllers.DeleteLottery)
 		lottery.GET("/check", controllers.CheckUserLottery)
 		lottery.GET("/analyze", controllers.AnalyzeUserLottery)
@@ -30,4 +103,33 @@ func SetupRoutes(router *gin.Engine) {
 		lottery.POST("/upload-image", controllers.UploadLotteryImage)
 		lottery.DELETE("/delete-image/:id", controllers.DeleteLotteryImage)
 	}
+
+	// ไฟล์ของ LocalBlobStore (STORAGE_DRIVER=local)
+	router.GET("/files/*key", controllers.ServeLocalBlob)
+	router.PUT("/files/*key", controllers.PutLocalBlob)
+
+	watchlist := router.Group("/watchlist")
+	watchlist.Use(middleware.AuthMiddleware())
+	{
+		watchlist.GET("/", controllers.ListWatchlist)
+		watchlist.POST("/", controllers.AddWatch)
+		watchlist.GET("/matches", controllers.ListWatchMatches)
+		watchlist.DELETE("/:id", controllers.DeleteWatch)
+	}
+
+	// สลากของกลุ่มใช้ /lottery ตามปกติโดยส่ง pool_id
+	pools := router.Group("/pools")
+	pools.Use(middleware.AuthMiddleware())
+	{
+		pools.POST("/", controllers.CreatePool)
+		pools.GET("/", controllers.ListPools)
+		pools.GET("/:id", controllers.GetPool)
+		pools.DELETE("/:id", controllers.DeletePool)
+		pools.GET("/:id/stats", controllers.GetPoolStats)
+		pools.POST("/:id/invite", controllers.InvitePoolMember)
+		pools.PUT("/:id/shares", controllers.SetPoolShares)
+		pools.POST("/:id/accept", controllers.AcceptPoolInvite)
+		pools.POST("/:id/leave", controllers.LeavePool)
+		pools.DELETE("/:id/members/:user_id", controllers.RemovePoolMember)
+	}
 }